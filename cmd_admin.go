@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// adminCmd manages the tenants of a shared `serve`:
+//
+//	admin user add [-name NAME] TELEGRAM_ID
+//	admin user remove TELEGRAM_ID
+//	admin user list
+//	admin room assign TELEGRAM_ID ROOM
+//	admin token issue [-scope read,check] [-user TELEGRAM_ID] NAME
+func adminCmd(args []string) {
+	const usage = "usage: admin user add|remove|list, admin room assign USER ROOM, admin token issue NAME"
+	if len(args) < 2 {
+		log.Fatal(usage)
+	}
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	configPath := configFlag(fs)
+	name := fs.String("name", "", "display name of the user")
+	scope := fs.String("scope", utils.ScopeRead, "comma-separated token scopes: read, check, notify, admin")
+	user := fs.String("user", "", "Telegram ID of the user the token acts for, limited to the user's rooms")
+	action := args[0] + " " + args[1]
+	fs.Parse(args[2:])
+	conf := utils.LoadConfig(*configPath)
+	state, err := conf.State.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch action {
+	case "user add":
+		if fs.NArg() != 1 {
+			log.Fatal("usage: admin user add [-name NAME] TELEGRAM_ID")
+		}
+		err = state.AddUser(fs.Arg(0), *name)
+	case "user remove":
+		if fs.NArg() != 1 {
+			log.Fatal("usage: admin user remove TELEGRAM_ID")
+		}
+		err = state.RemoveUser(fs.Arg(0))
+	case "user list":
+		users, err := state.Users()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, u := range users {
+			fmt.Printf("%s\t%s\t%s\n", u.ID, u.Name, strings.Join(u.Rooms, ","))
+		}
+	case "room assign":
+		if fs.NArg() != 2 {
+			log.Fatal("usage: admin room assign TELEGRAM_ID ROOM")
+		}
+		if room := fs.Arg(1); !slices.Contains(servedRooms(conf), room) {
+			log.Fatalf("unknown room %q, use one of %s", room, strings.Join(servedRooms(conf), ", "))
+		}
+		err = state.AssignRoom(fs.Arg(0), fs.Arg(1))
+	case "token issue":
+		if fs.NArg() != 1 {
+			log.Fatal("usage: admin token issue [-scope read,check] [-user TELEGRAM_ID] NAME")
+		}
+		secret, err := state.IssueUserToken(*user, fs.Arg(0), strings.Split(*scope, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(secret)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// servedRooms lists the room identifiers `serve` accepts: the main
+// room's number and ID and the names of the configured Rooms
+func servedRooms(conf *utils.Config) []string {
+	ids := []string{conf.RequestData.Room, conf.RequestData.RoomID}
+	for _, entry := range conf.Rooms {
+		ids = append(ids, entry.Label(conf.RequestData))
+	}
+	var rooms []string
+	for _, id := range ids {
+		if id != "" && !slices.Contains(rooms, id) {
+			rooms = append(rooms, id)
+		}
+	}
+	return rooms
+}
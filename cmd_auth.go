@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// authCmd authorizes Gmail sending and saves Email.TokenFile:
+//
+//	auth [-listen 127.0.0.1:8085]
+func authCmd(args []string) {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	configPath := configFlag(fs)
+	listen := fs.String("listen", "127.0.0.1:8085", "address of the local server receiving the OAuth redirect, e.g. 0.0.0.0:8085 in Docker")
+	timeout := fs.Duration("timeout", 10*time.Minute, "how long to wait for the browser")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	if conf.Email.SMTP.Enabled() {
+		log.Fatal("Email is sent through Email.SMTP, Gmail authorization is not needed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := conf.Email.Authorize(ctx, *listen, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Gmail authorized")
+}
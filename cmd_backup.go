@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils/store"
+)
+
+// backupCmd bundles the config and every data file into one archive:
+//
+//	backup [-o electricity-backup-20250110.tar.gz] [-no-secrets]
+func backupCmd(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := configFlag(fs)
+	out := fs.String("o", "", "archive to write, defaults to electricity-backup-DATE.tar.gz")
+	noSecrets := fs.Bool("no-secrets", false, "blank tokens and passwords in the config and leave out credential files")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	if *out == "" {
+		*out = "electricity-backup-" + time.Now().Format("20060102") + ".tar.gz"
+	}
+
+	config, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *noSecrets {
+		ext := strings.ToLower(filepath.Ext(*configPath))
+		if config, err = utils.RedactConfig(config, ext == ".yaml" || ext == ".yml"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	m := utils.BackupManifest{Created: time.Now(), Config: filepath.Base(*configPath), Redacted: *noSecrets}
+	tmp, err := os.MkdirTemp("", "electricity-backup")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, e := range conf.BackupEntries() {
+		if e.Secret && *noSecrets {
+			continue
+		}
+		if !utils.RestorablePath(e.Path) {
+			log.Printf("Leaving out %s: only relative paths inside the working directory can be restored", e.Path)
+			continue
+		}
+		// The database is copied through SQLite so that a running daemon
+		// cannot leave a torn copy
+		if e.Path == conf.Store.SQLite {
+			if _, err := os.Stat(e.Path); err != nil {
+				continue
+			}
+			e.Source = filepath.Join(tmp, "history.db")
+			if err := snapshotSQLite(e.Path, e.Source); err != nil {
+				log.Fatal(err)
+			}
+		}
+		m.Files = append(m.Files, e)
+	}
+
+	var b bytes.Buffer
+	if m, err = utils.WriteBackup(&b, m, config); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, b.Bytes(), 0600); err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range m.Files {
+		fmt.Println("  " + e.Path)
+	}
+	fmt.Printf("Backed up %s and %d file(s) to %s\n", *configPath, len(m.Files), *out)
+}
+
+// snapshotSQLite copies the history database consistently
+func snapshotSQLite(path, dst string) error {
+	db, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Snapshot(dst)
+}
+
+// restoreCmd unpacks an archive written by backup:
+//
+//	restore [-force] ARCHIVE
+func restoreCmd(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := configFlag(fs)
+	force := fs.Bool("force", false, "overwrite existing files")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: restore [-c config/config.json] [-force] ARCHIVE")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := utils.RestoreBackup(f, *configPath, *force)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range m.Files {
+		fmt.Println("  " + e.Path)
+	}
+	fmt.Printf("Restored %s and %d file(s) from the backup of %s\n", *configPath, len(m.Files), m.Created.Format("2006-01-02 15:04"))
+	if m.Redacted {
+		fmt.Println("The backup has no secrets: fill in the blanked tokens and passwords and run `auth` again if Gmail is used")
+	}
+}
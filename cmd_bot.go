@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// botCmd answers the buttons under Telegram warnings, the /settings
+// panel and the bot commands when checks run from cron rather than the
+// daemon
+func botCmd(args []string) {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	if conf.Telegram.BotToken == "" {
+		log.Fatal("Telegram.BotToken is not configured")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Println("Answering Telegram buttons, press Ctrl-C to stop")
+	var mu sync.Mutex
+	listenButtons(ctx, &conf.Telegram, &mu, func() *utils.App {
+		// Re-read so edits and earlier settings changes apply
+		if fresh, err := utils.ReadConfig(*configPath); err != nil {
+			log.Printf("Failed to reload config, keeping the previous one: %v", err)
+		} else {
+			conf = fresh
+		}
+		return newApp(conf)
+	})
+}
+
+// listenButtons answers button presses until ctx is done, preparing a
+// fresh app for each while holding mu so they never overlap a check
+func listenButtons(ctx context.Context, tg *utils.Telegram, mu *sync.Mutex, prepare func() *utils.App) {
+	err := tg.Listen(ctx, func(data string) (string, string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if utils.IsSettingsButton(data) {
+			return prepare().HandleSettings(data)
+		}
+		if utils.IsCommand(data) {
+			reply, err := prepare().HandleCommand(data)
+			return reply, "", err
+		}
+		reply, err := prepare().HandleButton(data)
+		return reply, "", err
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Stopped answering Telegram buttons: %v", err)
+	}
+}
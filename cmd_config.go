@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// configCmd works with configuration files:
+//
+//	config example > config.yaml
+//	config example -json > config.json
+//
+//go:generate sh -c "go run . config example -json > config/config.example.json"
+func configCmd(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the example as JSON, without comments")
+	if len(args) == 0 || args[0] != "example" {
+		log.Fatal("usage: config example [-json]")
+	}
+	fs.Parse(args[1:])
+
+	conf, err := utils.ExampleConfig()
+	if err != nil {
+		log.Fatalf("Built-in example config is invalid: %v", err)
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(conf, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return
+	}
+	b, err := utils.ExampleYAML(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(string(b))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// runDaemon checks right away and then on the configured schedule, and
+// sends the weekly report when one is scheduled. The config file is
+// re-read before every check so edits apply without a restart. It
+// returns once ctx is done, after letting a running check finish.
+func runDaemon(ctx context.Context, runtime *utils.RuntimeConfig, configPath string, prepare func(*utils.Config) *utils.App) {
+	conf, _ := runtime.Get()
+	if _, err := conf.Schedule.NextCheck(time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	// Metrics and health checks share a listener on the same address
+	muxes := map[string]*http.ServeMux{}
+	serve := func(listen, pattern string, h http.Handler) {
+		if muxes[listen] == nil {
+			muxes[listen] = http.NewServeMux()
+		}
+		muxes[listen].Handle(pattern, h)
+	}
+	if listen := conf.Metrics.Listen; listen != "" {
+		// Every check reports to the one exporter
+		exporter := utils.NewExporter()
+		serve(listen, "GET /metrics", exporter)
+		fmt.Println("Serving metrics on", listen)
+		check := prepare
+		prepare = func(conf *utils.Config) *utils.App {
+			app := check(conf)
+			exporter.Attach(app.Bus)
+			return app
+		}
+	}
+	var heartbeat *utils.Heartbeat
+	if listen := conf.Health.Listen; listen != "" {
+		heartbeat = utils.NewHeartbeat(conf.Health)
+		serve(listen, "GET /healthz", heartbeat)
+		fmt.Println("Serving health checks on", listen)
+	}
+	for listen, mux := range muxes {
+		go func() {
+			log.Fatal(http.ListenAndServe(listen, mux))
+		}()
+	}
+	nextCheck := prepare(conf).Clock.Now()
+	fmt.Println("Daemon started")
+
+	// mu keeps button presses from overlapping a check, and shutdown
+	// from cutting either short
+	var mu sync.Mutex
+	if conf.Telegram.Buttons {
+		go listenButtons(ctx, &conf.Telegram, &mu, func() *utils.App {
+			// Settings changed in the panel show up in the next press
+			if _, err := runtime.Reload(configPath); err != nil {
+				log.Printf("Failed to reload config, keeping the previous one: %v", err)
+			}
+			conf, _ := runtime.Get()
+			return prepare(conf)
+		})
+	}
+
+	for {
+		conf, _ := runtime.Get()
+		app := prepare(conf)
+		now := app.Clock.Now()
+
+		wake, report := nextCheck, false
+		if conf.Schedule.ReportWeekday != "" {
+			next, _, err := conf.Schedule.NextReport(now)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if next.Before(wake) {
+				wake, report = next, true
+			}
+		}
+		wait := max(wake.Sub(now), 0)
+		heartbeat.Scheduled(wait)
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			fmt.Println("Daemon stopped")
+			return
+		case <-time.After(wait):
+		}
+
+		if _, err := runtime.Reload(configPath); err != nil {
+			log.Printf("Failed to reload config, keeping the previous one: %v", err)
+		}
+		conf, _ = runtime.Get()
+		app = prepare(conf)
+
+		mu.Lock()
+		if report {
+			msg, err := app.Report(7)
+			if err == nil {
+				err = app.Notify(utils.Alert{Message: msg, Rule: "report"})
+			}
+			mu.Unlock()
+			if err != nil {
+				log.Printf("Weekly report failed: %v", err)
+			}
+			continue
+		}
+		summary := conf.Summary.Start(app.Bus)
+		err := app.RunAll()
+		summary.Finish(err)
+		heartbeat.Checked(err)
+		mu.Unlock()
+		if err != nil {
+			log.Println(err)
+		}
+		next, err := conf.Schedule.NextCheck(app.Clock.Now())
+		if err != nil {
+			log.Printf("%v, checking again in an hour", err)
+			next = app.Clock.Now().Add(time.Hour)
+		}
+		nextCheck = next
+		fmt.Println("Next check at", nextCheck.Format("2006-01-02 15:04:05"))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils/store"
+)
+
+// dbCmd maintains the SQLite history database:
+//
+//	db stats
+//	db vacuum
+//	db prune -before 2025-01-01
+//	db verify
+//	db repair
+func dbCmd(args []string) {
+	fs := flag.NewFlagSet("db", flag.ExitOnError)
+	configPath := configFlag(fs)
+	before := fs.String("before", "", "prune: delete readings taken before this time")
+	if len(args) == 0 {
+		log.Fatal("usage: db stats | vacuum | prune -before DATE | verify | repair")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+	conf := utils.LoadConfig(*configPath)
+	if conf.Store.SQLite == "" {
+		log.Fatal("db commands need Store.SQLite to be configured")
+	}
+	db, err := store.Open(conf.Store.SQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	switch action {
+	case "stats":
+		st, err := db.Stats()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Readings: %d\n", st.Readings)
+		if st.Readings > 0 {
+			fmt.Printf("Period:   %s to %s\n", st.First.Format("2006-01-02 15:04"), st.Last.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("Size:     %.1f KiB\n", float64(st.Bytes)/1024)
+	case "vacuum":
+		if err := db.Vacuum(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Vacuumed", conf.Store.SQLite)
+	case "prune":
+		if *before == "" {
+			log.Fatal("usage: db prune -before DATE")
+		}
+		t, err := utils.ParseTime(*before)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := db.Prune(t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Deleted %d readings before %s\n", n, t.Format("2006-01-02 15:04"))
+	case "verify":
+		problems, err := db.Verify()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(problems) == 0 {
+			fmt.Println("OK")
+			return
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		os.Exit(utils.ExitError)
+	case "repair":
+		n, err := db.Repair()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Removed %d bad readings\n", n)
+	default:
+		log.Fatalf("unknown db action %q", action)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// lastsUntilCmd answers whether the balance lasts until a date:
+//
+//	lasts-until 2025-01-10
+func lastsUntilCmd(args []string) {
+	fs := flag.NewFlagSet("lasts-until", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: lasts-until DATE")
+	}
+	app := newApp(utils.LoadConfig(*configPath))
+	target, err := utils.ParseTimeAt(fs.Arg(0), app.Clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, err := app.LastsUntil(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p.Format(app.Locale))
+}
+
+// reportCmd prints or sends the consumption report, e.g. weekly from cron
+func reportCmd(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	configPath := configFlag(fs)
+	days := fs.Int("days", 7, "period covered by the report")
+	send := fs.Bool("send", false, "send the report through the notification channels")
+	charts := fs.Bool("charts", false, "add a chart per room, sent as one album or written to the current directory")
+	fs.Parse(args)
+
+	app := newApp(utils.LoadConfig(*configPath))
+	msg, err := app.Report(*days)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*send {
+		fmt.Println(msg)
+	} else if err := app.Notify(utils.Alert{Message: msg}); err != nil {
+		log.Fatal(err)
+	}
+	if !*charts {
+		return
+	}
+
+	caption, photos, err := app.Charts(*days)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(photos) == 0 {
+		fmt.Println("Not enough history for charts yet")
+		return
+	}
+	if *send {
+		if err := app.SendAlbum(caption, photos); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, p := range photos {
+		if err := os.WriteFile(p.Name, p.PNG, 0644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Wrote", p.Name)
+	}
+}
+
+// statementCmd saves the monthly PDF statement, by default for the
+// previous month so that it fits a cron job on the 1st:
+//
+//	statement [-month 2025-01]
+func statementCmd(args []string) {
+	fs := flag.NewFlagSet("statement", flag.ExitOnError)
+	configPath := configFlag(fs)
+	month := fs.String("month", "", "month to account for as YYYY-MM, defaults to the previous month")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	app := newApp(conf)
+
+	var start time.Time
+	if *month == "" {
+		now := app.Clock.Now()
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	} else {
+		var err error
+		if start, err = time.ParseInLocation("2006-01", *month, time.Local); err != nil {
+			log.Fatalf("invalid month %q, use YYYY-MM", *month)
+		}
+	}
+	subs, err := conf.SubMeters.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	statement, err := app.Statement(start, subs, conf.Statements.Roommates)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pdf, err := statement.PDF(conf.Statements.Font)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path, err := conf.Statements.Save(start, pdf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wrote", path)
+	if !conf.Statements.Email {
+		return
+	}
+	subject := "Electricity statement " + start.Format("2006-01")
+	file := utils.Attachment{Name: filepath.Base(path), ContentType: "application/pdf", Data: pdf}
+	if err := conf.Email.SendFiles(subject, statement.Text(), file); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// batchCmd prints or sends one summary of the rooms running low
+func batchCmd(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	configPath := configFlag(fs)
+	send := fs.Bool("send", false, "send the summary through the notification channels")
+	fs.Parse(args)
+
+	app := newApp(utils.LoadConfig(*configPath))
+	alert, err := app.BatchSummary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*send {
+		fmt.Println(alert.Message)
+		return
+	}
+	if err := app.Notify(alert); err != nil {
+		log.Fatal(err)
+	}
+}
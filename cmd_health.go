@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// healthcheckCmd asks the running daemon's health endpoint and exits 1
+// unless it is healthy, for a Docker HEALTHCHECK in an image without curl
+func healthcheckCmd(args []string) {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	if conf.Health.Listen == "" {
+		log.Fatal("Health.Listen is not configured")
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(conf.Health.URL())
+	if err != nil {
+		fmt.Println("unhealthy:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(1)
+	}
+}
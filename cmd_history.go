@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// historyCmd answers questions about the recorded history:
+//
+//	history diff -from 2025-01-10T18:00 -to 2025-01-12T23:00
+func historyCmd(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	configPath := configFlag(fs)
+	from := fs.String("from", "", "diff: start of the period")
+	to := fs.String("to", "", "diff: end of the period, defaults to now")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	if len(args) == 0 || args[0] != "diff" {
+		log.Fatal("usage: history diff -from TIME [-to TIME]")
+	}
+	fs.Parse(args[1:])
+	if *from == "" {
+		log.Fatal("usage: history diff -from TIME [-to TIME]")
+	}
+
+	app := newApp(utils.LoadConfig(*configPath))
+	start, err := utils.ParseTimeAt(*from, app.Clock.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	end := app.Clock.Now()
+	if *to != "" {
+		if end, err = utils.ParseTimeAt(*to, app.Clock.Now()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	d, err := app.Diff(start, end)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(d)
+		return
+	}
+	fmt.Println(d.Format(app.Locale))
+}
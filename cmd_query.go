@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// queryCmd fetches once, without retries, and prints the remaining balance without sending
+// or storing anything. The exit code is 4 when a warning rule matched, so
+// scripts and status bars can react to a low balance.
+func queryCmd(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := configFlag(fs)
+	asJSON := fs.Bool("json", false, "print the full reading as JSON")
+	fs.Parse(args)
+
+	app := newApp(utils.LoadConfig(*configPath))
+	app.MaxRetries = 1
+	reading, err := app.Fetch()
+	if err != nil {
+		log.Print(err)
+		os.Exit(utils.ExitCode(err))
+	}
+	alert, err := app.Evaluate(reading)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	warning := alert.IsWarning()
+	if *asJSON {
+		out := struct {
+			utils.Reading
+			Rule    string `json:"rule"`
+			Warning bool   `json:"warning"`
+		}{reading, alert.Rule, warning}
+		b, err := json.Marshal(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Printf("%.2f\n", reading.Remaining)
+	}
+	if warning {
+		os.Exit(utils.ExitBelowThreshold)
+	}
+}
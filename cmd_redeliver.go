@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// redeliverCmd sends the messages in the webhook dead-letter file again,
+// keeping those that still fail
+func redeliverCmd(args []string) {
+	fs := flag.NewFlagSet("redeliver", flag.ExitOnError)
+	configPath := configFlag(fs)
+	list := fs.Bool("list", false, "only list the dead letters")
+	fs.Parse(args)
+
+	conf := utils.LoadConfig(*configPath)
+	if *list {
+		letters, err := conf.Webhooks.DeadLetters()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, l := range letters {
+			fmt.Printf("%s %s: %q (%s)\n", l.Time.Format("2006-01-02 15:04"), l.Channel, l.Message, l.Error)
+		}
+		return
+	}
+	sent, failed, err := newApp(conf).Redeliver(conf.Webhooks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Redelivered %d, %d still failing\n", sent, failed)
+	if failed > 0 {
+		os.Exit(utils.ExitDeliveryFailed)
+	}
+}
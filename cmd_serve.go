@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils/server"
+)
+
+// serveCmd runs the HTTP API
+func serveCmd(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := configFlag(fs)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+
+	app := newApp(conf)
+	srv := &server.Server{
+		App:   app,
+		Rooms: []string{conf.RequestData.Room, conf.RequestData.RoomID},
+		Apps:  app.RoomApps(),
+		Feed:  conf.Feed,
+		Audit: conf.Audit,
+		Login: conf.Login,
+		API:   conf.API,
+
+		BotToken: conf.Telegram.BotToken,
+	}
+	srv.Login.AllowedIDs = append(srv.Login.AllowedIDs, conf.Telegram.UserID)
+	fmt.Println("Serving API on", *listen)
+	httpServer := &http.Server{Addr: *listen, Handler: srv.Handler()}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		// Let triggered checks finish before exiting
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+		close(stopped)
+	}()
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+}
+
+// tokenCmd manages device tokens for the HTTP API:
+//
+//	token issue [-scope read,check,notify] NAME
+//	token list
+//	token revoke NAME
+func tokenCmd(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	configPath := configFlag(fs)
+	scope := fs.String("scope", utils.ScopeRead, "comma-separated scopes: read, check, notify, admin")
+	if len(args) == 0 {
+		log.Fatal("usage: token issue NAME | token list | token revoke NAME")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+	conf := utils.LoadConfig(*configPath)
+	state, err := conf.State.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch action {
+	case "issue":
+		if fs.NArg() != 1 {
+			log.Fatal("usage: token issue [-scope read,check] NAME")
+		}
+		secret, err := state.IssueToken(fs.Arg(0), strings.Split(*scope, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(secret)
+	case "list":
+		tokens, err := state.Tokens()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, t := range tokens {
+			fmt.Printf("%s\t%s\t%s\n", t.Name, strings.Join(t.Scopes, ","), t.Created.Format("2006-01-02"))
+		}
+	case "revoke":
+		if fs.NArg() != 1 {
+			log.Fatal("usage: token revoke NAME")
+		}
+		if err := state.RevokeToken(fs.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown token action %q", action)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// simulateCmd replays the stored history through the configured rules
+// and prints the alerts that would have fired, to tune thresholds
+// without waiting for real readings:
+//
+//	simulate -days 30
+func simulateCmd(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := configFlag(fs)
+	days := fs.Int("days", 30, "period of history to replay")
+	all := fs.Bool("all", false, "print every reading, not only those where the matched rule changes")
+	fs.Parse(args)
+
+	app := newApp(utils.LoadConfig(*configPath))
+	alerts, err := app.Simulate(app.Clock.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(alerts) == 0 {
+		fmt.Println("No stored readings to replay")
+		return
+	}
+
+	var warnings, changes int
+	last := ""
+	for _, alert := range alerts {
+		warning := alert.IsWarning()
+		if warning {
+			warnings++
+		}
+		if alert.Rule != last {
+			changes++
+		} else if !*all {
+			continue
+		}
+		last = alert.Rule
+		first, _, _ := strings.Cut(alert.Message, "\n")
+		mark := " "
+		if warning {
+			mark = "!"
+		}
+		fmt.Printf("%s %s %-10s %s\n", alert.Reading.Timestamp.Format("2006-01-02 15:04"), mark, alert.Rule, first)
+	}
+	span := alerts[len(alerts)-1].Reading.Timestamp.Sub(alerts[0].Reading.Timestamp)
+	fmt.Printf("\n%d readings over %.1f days: %d warnings, rule changed %d times\n",
+		len(alerts), span.Hours()/24, warnings, changes)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// snoozeCmd silences non-critical alerts:
+//
+//	snooze 24h | snooze 2025-02-10T08:00 | snooze off
+func snoozeCmd(args []string) {
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: snooze DURATION|TIME|off")
+	}
+	conf := utils.LoadConfig(*configPath)
+
+	var until time.Time
+	if arg := fs.Arg(0); arg != "off" {
+		if d, err := time.ParseDuration(arg); err == nil {
+			until = time.Now().Add(d)
+		} else if until, err = utils.ParseTime(arg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := conf.Snooze.Set(until); err != nil {
+		log.Fatal(err)
+	}
+	if until.IsZero() {
+		fmt.Println("Snooze cleared")
+	} else {
+		fmt.Println("Snoozed until", until.Format("2006-01-02 15:04"))
+	}
+}
+
+// outagesCmd forwards new planned outage notices for the building
+func outagesCmd(args []string) {
+	fs := flag.NewFlagSet("outages", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+	if conf.Outages.URL == "" {
+		log.Fatal("Outages.URL is not configured")
+	}
+
+	sent, err := newApp(conf).CheckOutages(conf.RequestData.Build)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Forwarded %d new outage notice(s)\n", sent)
+}
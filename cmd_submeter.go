@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// submeterCmd records sub-meter readings by hand or via webhooks:
+//
+//	submeter add NAME VALUE
+//	submeter serve [-listen :8081]
+func submeterCmd(args []string) {
+	fs := flag.NewFlagSet("submeter", flag.ExitOnError)
+	configPath := configFlag(fs)
+	listen := fs.String("listen", ":8081", "address for the webhook listener (serve)")
+	if len(args) == 0 {
+		log.Fatal("usage: submeter add NAME VALUE | submeter serve")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+	conf := utils.LoadConfig(*configPath)
+
+	switch action {
+	case "add":
+		if fs.NArg() != 2 {
+			log.Fatal("usage: submeter add NAME VALUE")
+		}
+		value, err := strconv.ParseFloat(fs.Arg(1), 64)
+		if err != nil {
+			log.Fatalf("Invalid sub-meter value: %v", err)
+		}
+		entry := utils.SubReading{Name: fs.Arg(0), Value: value, Time: time.Now()}
+		if err := conf.SubMeters.Add(entry); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Recorded %s: %.2f\n", entry.Name, entry.Value)
+	case "serve":
+		http.Handle("/submeter", conf.SubMeters.Handler(utils.SystemClock{}))
+		fmt.Println("Listening for sub-meter webhooks on", *listen)
+		log.Fatal(http.ListenAndServe(*listen, nil))
+	default:
+		log.Fatalf("unknown submeter action %q", action)
+	}
+}
+
+// leaderboardCmd prints or sends the roommate usage leaderboard
+func leaderboardCmd(args []string) {
+	fs := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+	configPath := configFlag(fs)
+	days := fs.Int("days", 7, "period covered by the leaderboard")
+	send := fs.Bool("send", false, "send the leaderboard through the notification channels")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+
+	entries, err := conf.SubMeters.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	since := time.Now().AddDate(0, 0, -*days)
+	msg := utils.FormatLeaderboard(utils.Leaderboard(entries, since), since)
+	if !*send {
+		fmt.Println(msg)
+		return
+	}
+	if err := newApp(conf).Notify(utils.Alert{Message: msg}); err != nil {
+		log.Fatal(err)
+	}
+}
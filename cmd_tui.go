@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// tuiCmd shows balance, history, forecast and recent alerts in the terminal
+func tuiCmd(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	configPath := configFlag(fs)
+	interval := fs.Duration("interval", 10*time.Minute, "how often to fetch a new reading")
+	fs.Parse(args)
+	conf := utils.LoadConfig(*configPath)
+
+	app := newApp(conf)
+	app.MaxRetries = 1
+	m := tuiModel{app: app, audit: conf.Audit, interval: *interval}
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// tuiModel is the state of the terminal view
+type tuiModel struct {
+	app      *utils.App
+	audit    utils.Audit
+	interval time.Duration
+
+	reading  utils.Reading
+	history  []utils.Reading
+	forecast utils.Projection
+	alerts   []utils.Event
+	err      error
+	fetching bool
+}
+
+// tuiRefreshed carries the result of a refresh
+type tuiRefreshed struct {
+	reading  utils.Reading
+	history  []utils.Reading
+	forecast utils.Projection
+	alerts   []utils.Event
+	err      error
+}
+
+type tuiTick struct{}
+
+func (m tuiModel) Init() tea.Cmd {
+	return m.refresh()
+}
+
+// refresh fetches and stores a reading, then reloads history and alerts
+func (m tuiModel) refresh() tea.Cmd {
+	app, audit := m.app, m.audit
+	return func() tea.Msg {
+		var res tuiRefreshed
+		res.reading, res.err = app.Fetch()
+		if res.err == nil {
+			if err := app.Store.Save(res.reading); err != nil {
+				res.err = err
+			}
+		}
+		since := app.Clock.Now().AddDate(0, 0, -7)
+		history, err := app.Series(since)
+		if err == nil {
+			res.history = history
+		}
+		if p, err := app.LastsUntil(app.Clock.Now().AddDate(1, 0, 0)); err == nil {
+			res.forecast = p
+		}
+		if alerts, err := audit.Events(since, utils.EventThreshold); err == nil {
+			res.alerts = alerts
+		}
+		return res
+	}
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "r":
+			if !m.fetching {
+				m.fetching = true
+				return m, m.refresh()
+			}
+		}
+	case tuiTick:
+		m.fetching = true
+		return m, m.refresh()
+	case tuiRefreshed:
+		m.fetching = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.reading = msg.reading
+		}
+		m.history, m.forecast, m.alerts = msg.history, msg.forecast, msg.alerts
+		return m, tea.Tick(m.interval, func(time.Time) tea.Msg { return tuiTick{} })
+	}
+	return m, nil
+}
+
+func (m tuiModel) View() string {
+	var b strings.Builder
+	b.WriteString("CUHKSZ Electricity\n\n")
+	if m.reading.Timestamp.IsZero() {
+		b.WriteString("Balance:   fetching...\n")
+	} else {
+		fmt.Fprintf(&b, "Balance:   %.2f of %.2f (as of %s)\n",
+			m.reading.Remaining, m.reading.Total, m.reading.Timestamp.Format("15:04"))
+	}
+	if m.err != nil {
+		fmt.Fprintf(&b, "Error:     %v\n", m.err)
+	}
+
+	var remaining []float64
+	for _, r := range m.history {
+		remaining = append(remaining, r.Remaining)
+	}
+	fmt.Fprintf(&b, "7 days:    %s\n", sparkline(remaining, 60))
+
+	switch {
+	case !m.forecast.Known:
+		b.WriteString("Forecast:  not enough history\n")
+	case m.forecast.Runout.IsZero():
+		fmt.Fprintf(&b, "Forecast:  %.2f per day, not running out soon\n", m.forecast.Rate)
+	default:
+		fmt.Fprintf(&b, "Forecast:  %.2f per day, runs out around %s\n",
+			m.forecast.Rate, m.forecast.Runout.Format("2006-01-02"))
+	}
+
+	b.WriteString("\nRecent alerts:\n")
+	if len(m.alerts) == 0 {
+		b.WriteString("  none\n")
+	}
+	for i := len(m.alerts) - 1; i >= 0 && i >= len(m.alerts)-5; i-- {
+		e := m.alerts[i]
+		fmt.Fprintf(&b, "  %s  %s\n", e.Time.Format("01-02 15:04"), strings.SplitN(e.Alert.Message, "\n", 2)[0])
+	}
+	b.WriteString("\nr refresh · q quit\n")
+	return b.String()
+}
+
+// sparkline draws values as block characters, keeping the last width
+func sparkline(values []float64, width int) string {
+	if len(values) == 0 {
+		return "no history"
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	bars := []rune("▁▂▃▄▅▆▇█")
+	var sb strings.Builder
+	for _, v := range values {
+		i := 0
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(bars)-1))
+		}
+		sb.WriteRune(bars[i])
+	}
+	return sb.String()
+}
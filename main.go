@@ -1,76 +1,399 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils/store"
 )
 
+// commands are the subcommands selected by the first argument;
+// without one a single check is run
+var commands = map[string]func(args []string){
+	"submeter":    submeterCmd,
+	"leaderboard": leaderboardCmd,
+	"serve":       serveCmd,
+	"token":       tokenCmd,
+	"lasts-until": lastsUntilCmd,
+	"report":      reportCmd,
+	"snooze":      snoozeCmd,
+	"outages":     outagesCmd,
+	"query":       queryCmd,
+	"batch":       batchCmd,
+	"tui":         tuiCmd,
+	"db":          dbCmd,
+	"simulate":    simulateCmd,
+	"redeliver":   redeliverCmd,
+	"history":     historyCmd,
+	"config":      configCmd,
+	"auth":        authCmd,
+	"statement":   statementCmd,
+	"bot":         botCmd,
+	"admin":       adminCmd,
+	"backup":      backupCmd,
+	"restore":     restoreCmd,
+	"healthcheck": healthcheckCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	runCmd(os.Args[1:])
+}
+
+// configFlag registers the shared -c flag on a command's flag set
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("c", "config/config.json", "config.json file path")
+}
+
+// runCmd fetches once and sends the resulting notifications
+func runCmd(args []string) {
 	// Load the config file path from command-line arguments
-	var configPath string
-	flag.StringVar(&configPath, "c", "config/config.json", "config.json file path")
-	flag.Parse()
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	configPath := configFlag(fs)
+	now := fs.String("now", "", "pretend the current time is this (e.g. 2025-01-10T03:00 or 03:00)")
+	dryRun := fs.Bool("dry-run", false, "print notifications instead of sending them")
+	daemon := fs.Bool("daemon", false, "keep running and check on the configured schedule")
+	inject, err := utils.InjectionFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Failure injection is for testing the configuration and left out of the usage
+	fs.Float64Var(&inject.FetchError, "inject-fetch-error", inject.FetchError, "probability that a fetch attempt fails on purpose")
+	fs.DurationVar(&inject.NotifyLatency, "inject-notify-latency", inject.NotifyLatency, "delay added before every notification")
+	fs.Usage = func() {
+		visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		visible.SetOutput(fs.Output())
+		fs.VisitAll(func(f *flag.Flag) {
+			if !strings.HasPrefix(f.Name, "inject-") {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		visible.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := inject.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if inject.Enabled() {
+		log.Printf("Injecting failures: fetch error rate %g, notify latency %s", inject.FetchError, inject.NotifyLatency)
+	}
 
 	// Load the configuration from the JSON file
-	conf := utils.LoadConfig(configPath)
-
-	// Retry logic parameters
-	count, maxRetries, sleepSeconds := 0, 5, 5
-	var msg string
-	var err error
+	runtime := utils.NewRuntimeConfig(utils.LoadConfig(*configPath))
 
-	// Retry loop to get the message
-	for count < maxRetries {
-		msg, err = conf.RequestData.GetMsg() // Get the message from the API
-		if err != nil || msg == "Failed to retrieve data" {
-			count++
-			fmt.Printf("Attempt %d failed, retrying... Error: %v\n", count, err)
-			time.Sleep(time.Duration(sleepSeconds) * time.Second)
-		} else {
-			break
+	var clock utils.Clock
+	if *now != "" {
+		start, err := utils.ParseTime(*now)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clock = utils.NewShiftedClock(start)
+	}
+	// prepare wires the application core for one check
+	prepare := func(conf *utils.Config) *utils.App {
+		app := newApp(conf)
+		if clock != nil {
+			app.Clock = clock
+		}
+		app.Inject = inject
+		if *dryRun {
+			for i, ch := range app.Channels {
+				app.Channels[i].Notifier = &utils.ConsoleNotifier{Channel: ch.Name()}
+			}
 		}
+		return app
 	}
 
-	// Handle failure after maximum retries
-	if count == maxRetries {
-		errMsg := "Error: Maximum retry limit reached."
-		conf.Telegram.SendMsg(errMsg)
-		// Send email for critical errors
-		if emailErr := conf.Email.SendEmail(errMsg); emailErr != nil {
-			log.Printf("Failed to send email notification: %v", emailErr)
+	conf, _ := runtime.Get()
+	lock, err := conf.Lock.Acquire(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *daemon {
+		// Containers stop with SIGTERM; as PID 1 it is ignored unless handled
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		runDaemon(ctx, runtime, *configPath, prepare)
+		lock.Release()
+		return
+	}
+	app := prepare(conf)
+	summary := conf.Summary.Start(app.Bus)
+	err = app.RunAll()
+	summary.Finish(err)
+	lock.Release()
+	if err != nil {
+		log.Println(err)
+		os.Exit(utils.ExitCode(err))
+	}
+}
+
+// limiter is shared by the apps of the process, so that the daemon's
+// checks and its Telegram buttons draw on one upstream budget
+var limiter struct {
+	sync.Mutex
+	conf    utils.RateLimit
+	limiter *utils.Limiter
+}
+
+// sharedLimiter returns the process's limiter, starting a new one when
+// the configuration changed
+func sharedLimiter(conf utils.RateLimit) *utils.Limiter {
+	if !conf.Enabled() {
+		return nil
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+	if limiter.limiter == nil || limiter.conf != conf {
+		limiter.conf, limiter.limiter = conf, utils.NewLimiter(conf)
+	}
+	return limiter.limiter
+}
+
+// databases are the SQLite stores opened by the process, by path. The
+// daemon wires a new app for every check and button press, and reusing
+// the handle keeps those from each opening the database again.
+var databases struct {
+	sync.Mutex
+	open map[string]*store.SQLite
+}
+
+// sharedDatabase returns the process's handle on the SQLite store at
+// path, opening it on first use
+func sharedDatabase(path string) (*store.SQLite, error) {
+	databases.Lock()
+	defer databases.Unlock()
+	if db, ok := databases.open[path]; ok {
+		return db, nil
+	}
+	db, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if databases.open == nil {
+		databases.open = map[string]*store.SQLite{}
+	}
+	databases.open[path] = db
+	return db, nil
+}
+
+// newApp wires the application core from the loaded configuration
+func newApp(conf *utils.Config) *utils.App {
+	app := &utils.App{
+		Fetcher: &conf.RequestData,
+		Channels: []utils.Channel{
+			{Notifier: &conf.Telegram, Critical: true},
+			// Only send email for warning messages
+			{Notifier: &conf.Email, WarningsOnly: true},
+		},
+		Clock:      utils.SystemClock{},
+		MaxRetries: 5,
+		RetryDelay: 5 * time.Second,
+		Bus:        utils.NewBus(),
+		Policy:     conf.Policy,
+		Delivery:   conf.Delivery,
+		Tips:       conf.Tips,
+		Away:       conf.Away,
+		Calendar:   conf.Calendar,
+		TopUp:      conf.TopUp,
+		Weather:    conf.Weather,
+		Pricing:    conf.Pricing,
+		Snooze:     conf.Snooze,
+		Outages:    conf.Outages,
+		Sanity:     conf.Sanity,
+		Queue:      conf.Queue,
+		Batch:      conf.Batch,
+		Schedule:   conf.Schedule,
+		Rounding:   conf.Rounding,
+		Backfill:   conf.Backfill,
+		MultiRoom:  conf.MultiRoom,
+		Staleness:  conf.Staleness,
+		Goals:      conf.Goals,
+		Escalation: conf.Escalation,
+		QuietHours: conf.QuietHours,
+		Batching:   conf.Batching,
+		DataFeeds:  conf.DataFeeds,
+		MQTT:       conf.MQTT,
+		Locale:     conf.Locale,
+		Settings:   conf.Settings,
+		Limiter:    sharedLimiter(conf.RateLimit),
+		Fields:     conf.Fields,
+		Label:      strings.TrimSpace(conf.RequestData.Build + " " + conf.RequestData.Room),
+
+		NotifyTimeout: time.Duration(conf.Policy.NotifyTimeout) * time.Second,
+	}
+	if err := conf.Policy.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Delivery.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.QuietHours.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Telegram.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Email.SMTP.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Locale.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.RequestData.Schema.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.API.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.MQTT.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.Away.Validate(conf.Store); err != nil {
+		log.Fatal(err)
+	}
+	var history utils.Store
+	var err error
+	if conf.Store.SQLite != "" {
+		if conf.Encryption.Enabled() {
+			log.Fatal("Encryption covers Store.File and State.File, not Store.SQLite")
 		}
-		log.Fatal(errMsg)
+		history, err = sharedDatabase(conf.Store.SQLite)
 	} else {
-		// Send the successful message via Telegram
-		err = conf.Telegram.SendMsg(msg)
+		history, err = conf.Store.Open()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	app.Store = history
+	if app.State, err = conf.State.Open(); err != nil {
+		log.Fatal(err)
+	}
+	if conf.Telegram.Poll.Enabled {
+		app.Poll = &conf.Telegram
+	}
+	conf.Audit.Attach(app.Bus)
+
+	// Swap in fake providers for credential-free end-to-end runs
+	if utils.IsFake(conf.RequestData.API) {
+		fetcher, err := utils.NewFakeFetcher(conf.RequestData.API)
 		if err != nil {
-			log.Printf("Failed to send Telegram message: %v", err)
-		} else {
-			fmt.Println("Telegram message sent successfully:", msg)
+			log.Fatal(err)
 		}
+		app.Fetcher = fetcher
+	}
+	for _, entry := range conf.Rooms {
+		room, err := entry.Room(conf.RequestData)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.Rooms = append(app.Rooms, room)
+	}
+	// Webhook channels are added when configured and retried per Webhooks
+	webhooks := []struct {
+		enabled      bool
+		notifier     utils.Notifier
+		warningsOnly bool
+	}{
+		{conf.Slack.Configured(), &conf.Slack, conf.Slack.WarningsOnly},
+		{conf.WeCom.WebhookURL != "", &conf.WeCom, conf.WeCom.WarningsOnly},
+		{conf.DingTalk.WebhookURL != "", &conf.DingTalk, conf.DingTalk.WarningsOnly},
+		{conf.Feishu.WebhookURL != "", &conf.Feishu, conf.Feishu.WarningsOnly},
+		{conf.Bark.DeviceKey != "", &conf.Bark, conf.Bark.WarningsOnly},
+		{conf.ServerChan.SendKey != "", &conf.ServerChan, conf.ServerChan.WarningsOnly},
+		{conf.Gotify.URL != "", &conf.Gotify, conf.Gotify.WarningsOnly},
+		{conf.Pushover.Token != "", &conf.Pushover, conf.Pushover.WarningsOnly},
+		{conf.PushDeer.PushKey != "", &conf.PushDeer, conf.PushDeer.WarningsOnly},
+		{conf.Webhook.URL != "", &conf.Webhook, conf.Webhook.WarningsOnly},
+	}
+	for _, w := range webhooks {
+		if w.enabled {
+			app.Channels = append(app.Channels, utils.Channel{Notifier: conf.Webhooks.Wrap(w.notifier), WarningsOnly: w.warningsOnly})
+		}
+	}
+	if conf.Twilio.AccountSID != "" {
+		app.Channels = append(app.Channels, utils.Channel{Notifier: &conf.Twilio, CriticalOnly: true})
+	}
+	// fakes maps each built-in channel to the address that selects its fake
+	fakes := map[string]string{
+		"Telegram":   conf.Telegram.APIHost,
+		"Email":      conf.Email.CredentialsFile,
+		"Slack":      conf.Slack.WebhookURL,
+		"WeCom":      conf.WeCom.WebhookURL,
+		"DingTalk":   conf.DingTalk.WebhookURL,
+		"Feishu":     conf.Feishu.WebhookURL,
+		"Bark":       conf.Bark.Server,
+		"ServerChan": conf.ServerChan.APIHost,
+		"Gotify":     conf.Gotify.URL,
+		"Pushover":   conf.Pushover.APIHost,
+		"PushDeer":   conf.PushDeer.Server,
+		"SMS":        conf.Twilio.APIHost,
+		"Webhook":    conf.Webhook.URL,
+	}
+	for i, ch := range app.Channels {
+		if utils.IsFake(fakes[ch.Name()]) {
+			app.Channels[i].Notifier = &utils.ConsoleNotifier{Channel: ch.Name()}
+		}
+	}
 
-		// Only send email for warning messages
-		if isWarning(msg) {
-			emailErr := conf.Email.SendEmail(msg)
-			if emailErr != nil {
-				log.Printf("Failed to send email: %v", emailErr)
-			} else {
-				fmt.Println("Email sent successfully:", msg)
-			}
+	// Attach external plugins discovered in the plugins directory
+	plugins, err := conf.Plugins.DiscoverPlugins()
+	if err != nil {
+		log.Printf("Failed to load plugins: %v", err)
+	}
+	for _, p := range plugins {
+		if p.Has("notifier") {
+			app.Channels = append(app.Channels, utils.Channel{Notifier: p})
+		}
+		if p.Has("fetcher") && p.Name() == conf.Plugins.Fetcher {
+			app.Fetcher = p
 		}
+	}
 
-		// Only exit with error if Telegram failed (email is optional for non-warnings)
-		if err != nil {
-			log.Fatal("Telegram delivery failed")
+	app.Channels = conf.Public.Apply(app.Channels)
+	app.Channels = conf.Escalation.Apply(app.Channels)
+	app.Channels = conf.Audit.Apply(app.Channels)
+
+	// Compile the alert rules, falling back to the built-in thresholds
+	ruleList, templates := conf.Rules, conf.Templates
+	if len(conf.Tiers) > 0 {
+		var tierTemplates map[string]string
+		if ruleList, tierTemplates, err = utils.TierRules(conf.Tiers, conf.Rules); err != nil {
+			log.Fatal(err)
 		}
+		for name, text := range conf.Templates {
+			tierTemplates[name] = text
+		}
+		templates = tierTemplates
 	}
-}
+	rules, err := utils.NewRuleSet(ruleList, templates)
+	if err != nil {
+		log.Fatal(err)
+	}
+	app.Rules = rules
 
-// isWarning checks if the message contains warning information
-func isWarning(msg string) bool {
-	return len(msg) >= 7 && msg[:7] == "Warning"
+	// Load user hook scripts
+	if conf.Hooks.Script != "" {
+		script, err := conf.Hooks.LoadScript()
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.Hooks = script
+	}
+	return app
 }
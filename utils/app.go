@@ -0,0 +1,496 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Fetcher retrieves the current electricity reading
+type Fetcher interface {
+	GetMsg() (Reading, error)
+}
+
+// Notifier delivers a message through a single channel
+type Notifier interface {
+	Name() string
+	Notify(msg string) error
+}
+
+// AlertNotifier is a Notifier that also wants the alert behind the
+// message, e.g. to post the reading as structured data
+type AlertNotifier interface {
+	Notifier
+	NotifyAlert(alert Alert, msg string) error
+}
+
+// Channel pairs a notifier with its delivery rules
+type Channel struct {
+	Notifier
+	WarningsOnly bool // only deliver warning messages
+	CriticalOnly bool // only deliver exceeded-limit and critical messages
+	Critical     bool // a delivery failure fails the whole run
+	Public       bool // semi-public, only gets the public rendering of alerts
+	Escalation   bool // secondary contact, only gets escalations
+	Audit        bool // logs the rendered message to the audit log instead of sending
+}
+
+// App is the monitoring pipeline shared by every entry point
+type App struct {
+	Fetcher    Fetcher
+	Channels   []Channel
+	Store      Store
+	Clock      Clock
+	Hooks      Hooks    // optional
+	Rules      *RuleSet // optional, defaults to the built-in thresholds
+	Bus        *Bus     // optional, receives run events
+	Policy     Policy
+	Delivery   Delivery
+	Tips       Tips
+	Away       Away
+	Calendar   Calendar
+	State      *State    // persisted bookkeeping between runs
+	Poll       *Telegram // optional, sends top-up polls
+	TopUp      TopUp
+	Weather    Weather
+	Pricing    Pricing
+	Snooze     Snooze
+	Outages    Outages
+	Sanity     Sanity
+	Queue      Queue
+	Rooms      []Room // further rooms for the batch summary
+	Batch      Batch
+	Schedule   Schedule
+	Rounding   Rounding
+	Backfill   Backfill
+	MultiRoom  MultiRoom
+	Staleness  Staleness
+	Goals      Goals
+	Escalation Escalation
+	QuietHours QuietHours
+	Batching   Batching
+	DataFeeds  DataFeeds
+	MQTT       MQTT
+	Locale     Locale
+	Settings   Settings
+	Fields     []Field // computed before the rules run
+	Label      string  // room name used when several rooms share the channels
+	MaxRetries int
+	RetryDelay time.Duration
+	Inject     Injection // failures injected on purpose, for resilience testing
+	Limiter    *Limiter  // optional, shared by every fetch trigger of the process
+
+	NotifyTimeout time.Duration // per-channel limit, defaults to 30s
+
+	batch *notifyBatch // alerts held back during RunAll
+}
+
+// ErrMaxRetries is returned when the fetcher keeps failing
+var ErrMaxRetries = errors.New("Error: Maximum retry limit reached.")
+
+// Fetch gets the current reading, retrying the fetcher on failure.
+// Readings without a timestamp are stamped with the app clock.
+func (a *App) Fetch() (Reading, error) {
+	return a.fetchWith(a.Fetcher.GetMsg)
+}
+
+// fetchWith retries get, then stamps and rounds the reading
+func (a *App) fetchWith(get func() (Reading, error)) (reading Reading, err error) {
+	err = a.retry(func() (err error) {
+		reading, err = a.Limiter.fetch(a.roomKey("reading"), func() (Reading, error) {
+			return a.Inject.fetch(get)
+		})
+		return
+	})
+	if err != nil {
+		return Reading{}, err
+	}
+	if reading.Timestamp.IsZero() {
+		reading.Timestamp = a.Clock.Now()
+	}
+	return a.Rounding.Apply(reading), nil
+}
+
+// Evaluate turns a reading into an alert using the rule set.
+// The daily consumption rate over the last day is exposed as rate, the
+// usage since the previous reading as since_last and the calendar-aware
+// depletion forecast as days_left and runout.
+func (a *App) Evaluate(r Reading) (Alert, error) {
+	rules := a.Rules
+	if rules == nil {
+		var err error
+		if rules, err = NewRuleSet(nil, nil); err != nil {
+			return Alert{}, err
+		}
+	}
+
+	vars := r.Vars()
+	vars["timestamp"] = a.Locale.DateTime(r.Timestamp)
+	vars["rate"], vars["has_rate"] = 0.0, false
+	vars["days_left"], vars["runout"] = -1.0, ""
+	vars["since_last"] = -1.0
+	vars["threshold"] = a.Settings.threshold()
+	history, err := a.Store.History(r.Timestamp.Add(-24 * time.Hour))
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+	}
+	if prev, ok := lastBefore(history, r); ok && r.Used >= prev.Used {
+		vars["since_last"] = r.Used - prev.Used
+	}
+	if rate, ok := ConsumptionRate(history, r); ok {
+		vars["rate"], vars["has_rate"] = rate, true
+		if runout, ok := ForecastRunout(r.Timestamp, r.Remaining, rate, &a.Calendar); ok {
+			vars["days_left"] = runout.Sub(r.Timestamp).Hours() / 24
+			vars["runout"] = runout.Format("2006-01-02")
+		}
+	}
+	a.computeFields(r, vars)
+
+	alert, err := rules.Evaluate(vars)
+	alert.ID = NewAlertID()
+	alert.Reading = r
+	if channels, ok := rules.route(alert.Rule, r.Timestamp); ok {
+		alert.Channels = channels
+	}
+	if err == nil && a.Tips.Applies(alert.Rule) {
+		tips, err := a.Tips.Load()
+		if err != nil {
+			log.Printf("Failed to load tips: %v", err)
+		} else if tip := pickTip(tips, r); tip != "" {
+			alert.Message += "\n" + tip
+		}
+	}
+	return alert, err
+}
+
+// retry calls fn until it succeeds or MaxRetries is reached. An
+// unchanged reading is an answer rather than a failure and returned as is.
+func (a *App) retry(fn func() error) error {
+	for count := 1; count <= a.MaxRetries; count++ {
+		err := fn()
+		if err == nil || errors.Is(err, ErrUnchanged) {
+			return err
+		}
+		fmt.Printf("Attempt %d failed, retrying... Error: %v\n", count, err)
+		a.Clock.Sleep(a.RetryDelay)
+	}
+	return ErrMaxRetries
+}
+
+// Notify delivers the alert to every channel that accepts it.
+// Failures are judged by the failure policy.
+func (a *App) Notify(alert Alert) error {
+	if alert.ID == "" {
+		alert.ID = NewAlertID()
+	}
+	if alert.Time.IsZero() {
+		alert.Time = a.Clock.Now()
+	}
+	var jobs []delivery
+	for _, ch := range a.Channels {
+		alert := alert
+		if ch.Escalation != (alert.Rule == "escalation") {
+			continue
+		}
+		if ch.Public {
+			if alert.Public == "" {
+				continue
+			}
+			alert.Message = alert.Public
+		}
+		msg := alert.Message
+		if !alert.Targets(ch.Name()) || (ch.WarningsOnly && !alert.IsWarning()) || (ch.CriticalOnly && !alert.IsCritical()) {
+			continue
+		}
+		out := msg
+		if a.Hooks != nil {
+			var keep bool
+			var err error
+			if out, keep, err = a.Hooks.BeforeNotify(ch.Name(), alert); err != nil {
+				log.Printf("Hook error, sending original message: %v", err)
+				out = msg
+			} else if !keep {
+				fmt.Printf("%s notification suppressed by hook\n", ch.Name())
+				continue
+			}
+		}
+		jobs = append(jobs, delivery{Channel: ch, msg: out + "\nAlert #" + alert.ID, alert: &alert})
+	}
+	jobs = a.holdQuiet(jobs)
+	if a.batch != nil {
+		return a.hold(alert, jobs)
+	}
+	return a.deliver(alert, jobs)
+}
+
+// deliver sends the jobs of an alert and judges the outcome
+func (a *App) deliver(alert Alert, jobs []delivery) error {
+	jobs = a.distribute(jobs)
+
+	var attempted, failed, critical []string
+	var undelivered []delivery
+	for _, job := range jobs {
+		sent := alert
+		sent.Message = job.msg
+		attempted = append(attempted, job.Name())
+		if job.err != nil {
+			log.Printf("Failed to send %s notification: %v", job.Name(), job.err)
+			a.publish(Event{Kind: EventDeliveryFailed, Alert: sent, Channel: job.Name(), Duration: job.took, Err: job.err})
+			failed = append(failed, job.Name())
+			undelivered = append(undelivered, job)
+			if job.Critical {
+				critical = append(critical, job.Name())
+			}
+			continue
+		}
+		if job.Audit {
+			fmt.Printf("%s notification audited, not sent: %s\n", job.Name(), job.msg)
+			continue
+		}
+		a.publish(Event{Kind: EventDelivered, Alert: sent, Channel: job.Name(), Duration: job.took})
+		fmt.Printf("%s notification sent successfully: %s\n", job.Name(), job.msg)
+	}
+	if a.Delivery.Mode == FallbackMode {
+		// The chain as a whole failed only if nothing got through
+		if len(failed) > 0 && len(failed) == len(attempted) {
+			a.shelve(undelivered[:1])
+		}
+		return a.Policy.fallbackResult(attempted, failed)
+	}
+	a.shelve(undelivered)
+	return a.Policy.deliveryResult(attempted, failed, critical)
+}
+
+// delivery is one message bound for one channel
+type delivery struct {
+	Channel
+	msg   string
+	alert *Alert // behind msg, nil for merged messages
+	err   error
+	took  time.Duration
+}
+
+// warning reports whether the job carries a warning
+func (d delivery) warning() bool {
+	if d.alert != nil {
+		return d.alert.IsWarning()
+	}
+	return IsWarning(d.msg)
+}
+
+// level is the severity of the job's alert or message
+func (d delivery) level() string {
+	if d.alert != nil {
+		return d.alert.Severity()
+	}
+	return MessageLevel(d.msg)
+}
+
+// distribute sends the jobs as Delivery.Mode says: all at once, or down
+// the fallback chain until one succeeds. It returns the attempted jobs.
+func (a *App) distribute(jobs []delivery) []delivery {
+	if a.Delivery.Mode == FallbackMode {
+		return a.fallback(jobs)
+	}
+	a.dispatch(jobs)
+	return jobs
+}
+
+// dispatch sends every job concurrently and records the outcome in the job
+func (a *App) dispatch(jobs []delivery) {
+	timeout := a.notifyTimeout()
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(job *delivery) {
+			defer wg.Done()
+			a.send(job, timeout)
+		}(&jobs[i])
+	}
+	wg.Wait()
+}
+
+// send delivers a single job, isolated from panics and bounded by timeout
+func (a *App) send(job *delivery, timeout time.Duration) {
+	if job.Audit {
+		alert := Alert{}
+		if job.alert != nil {
+			alert = *job.alert
+		}
+		alert.Message = job.msg
+		a.publish(Event{Kind: EventAudited, Alert: alert, Channel: job.Name()})
+		return
+	}
+	start := time.Now()
+	defer func() { job.took = time.Since(start) }()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("notifier panicked: %v", r)
+			}
+		}()
+		a.Inject.delay()
+		// Merged and queued messages still carry the app's time
+		alert := job.alert
+		if alert == nil {
+			alert = &Alert{Message: job.msg, Time: a.Clock.Now()}
+		}
+		if r, ok := job.Notifier.(*retrying); ok {
+			// Keep the retries within the timeout instead of past it
+			done <- r.notifyBy(start.Add(timeout), alert, job.msg)
+			return
+		}
+		if n, ok := job.Notifier.(AlertNotifier); ok {
+			done <- n.NotifyAlert(*alert, job.msg)
+			return
+		}
+		done <- job.Notify(job.msg)
+	}()
+	select {
+	case job.err = <-done:
+	case <-time.After(timeout):
+		job.err = fmt.Errorf("notifier timed out after %s", timeout)
+	}
+}
+
+// notifyTimeout is the per-channel delivery limit
+func (a *App) notifyTimeout() time.Duration {
+	if a.NotifyTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return a.NotifyTimeout
+}
+
+// Run performs one complete fetch and notify cycle
+func (a *App) Run() error {
+	if a.awaySkip() {
+		fmt.Println("Away mode: checked recently, skipping this run")
+		return nil
+	}
+	a.FlushQueue()
+
+	reading, err := a.fetchChanged()
+	if errors.Is(err, ErrUnchanged) {
+		a.recordFetch(true)
+		fmt.Println("Reading unchanged since the last check, nothing to do")
+		return nil
+	}
+	streak := a.recordFetch(err == nil)
+	if err != nil {
+		a.publish(Event{Kind: EventFetchFailed, Err: err})
+		if streak < a.Policy.FailureStreak {
+			fmt.Printf("Fetch failed %d of %d times in a row before alerting\n", streak, a.Policy.FailureStreak)
+			return a.Policy.fetchResult(err)
+		}
+		// Report the fetch failure on every private channel, including
+		// warning-only ones; critical-only channels wait for real alerts
+		msg := err.Error()
+		if streak > 1 {
+			msg += fmt.Sprintf("\nFailed %d runs in a row", streak)
+		}
+		if last, ok := a.previous(Reading{Timestamp: a.Clock.Now()}); ok {
+			msg += fmt.Sprintf("\nLast known remaining: %.2f at %s", last.Remaining, a.Locale.DateTime(last.Timestamp))
+		}
+		var jobs []delivery
+		failure := &Alert{Message: msg, Time: a.Clock.Now()}
+		for _, ch := range a.Channels {
+			if !ch.Public && !ch.Escalation && !ch.CriticalOnly {
+				jobs = append(jobs, delivery{Channel: ch, msg: msg, alert: failure})
+			}
+		}
+		var undelivered []delivery
+		for _, job := range a.distribute(a.holdQuiet(jobs)) {
+			if job.err != nil {
+				log.Printf("Failed to send %s notification: %v", job.Name(), job.err)
+				undelivered = append(undelivered, job)
+			}
+		}
+		a.deadLetter(undelivered)
+		return a.Policy.fetchResult(err)
+	}
+
+	_, _, err = a.Check(reading, true)
+	return err
+}
+
+// Check runs a fetched reading through the steps every check shares:
+// the sanity check, backfill, history, data feed and rules. With notify
+// it goes on to deliver the alert as Run does, goals, snooze and away
+// mode included. ok is false when the reading was rejected as
+// implausible; the data-quality notice is sent either way.
+func (a *App) Check(reading Reading, notify bool) (alert Alert, ok bool, err error) {
+	if ok, err := a.checkSanity(reading); !ok {
+		return Alert{}, false, err
+	}
+	if err := a.backfill(); err != nil {
+		log.Print(err)
+	}
+	if err := a.Store.Save(reading); err != nil {
+		log.Printf("Failed to save reading: %v", err)
+	}
+	a.shareReading(reading, a.Label)
+	alert, err = a.Evaluate(reading)
+	if err != nil || !notify {
+		return alert, true, err
+	}
+	return alert, true, a.report(alert)
+}
+
+// report sends an evaluated alert unless it is snoozed, suppressed in
+// away mode or dropped by a hook
+func (a *App) report(alert Alert) error {
+	a.checkGoals(alert.Reading)
+	a.checkEscalation(alert.Reading)
+	if a.MultiRoom.Notify == PerRoom && a.Label != "" {
+		alert.Message += "\nRoom: " + a.Label
+	}
+	if a.snoozed(alert) {
+		fmt.Println("Snoozed, alert not sent:", alert.Message)
+		a.publish(Event{Kind: EventAlertSuppressed, Alert: alert})
+		return nil
+	}
+	if !a.applyAway(&alert) {
+		fmt.Println("Away mode: routine report suppressed:", alert.Message)
+		a.publish(Event{Kind: EventAlertSuppressed, Alert: alert})
+		return nil
+	}
+	a.applyStaleness(&alert)
+	a.applyTopUp(&alert)
+	a.applyPoll(&alert)
+	a.publish(Event{Kind: EventReading, Alert: alert})
+	if alert.IsWarning() {
+		a.publish(Event{Kind: EventThreshold, Alert: alert})
+	}
+
+	if a.Hooks != nil {
+		out, keep, err := a.Hooks.AfterFetch(alert)
+		if err != nil {
+			log.Printf("Hook error, keeping original message: %v", err)
+		} else if !keep {
+			fmt.Println("Message suppressed by hook:", alert.Message)
+			a.publish(Event{Kind: EventAlertSuppressed, Alert: alert})
+			return nil
+		} else {
+			alert.Message = out
+			// A hook may turn the message into a warning
+			if IsWarning(out) && !alert.IsWarning() {
+				alert.Level = LevelWarning
+			}
+		}
+	}
+	return a.Notify(alert)
+}
+
+// publish stamps e with the current time and room and sends it on the bus
+func (a *App) publish(e Event) {
+	e.Time, e.Room = a.Clock.Now(), a.Label
+	a.Bus.Publish(e)
+}
+
+// IsWarning checks if the message contains warning information
+func IsWarning(msg string) bool {
+	return len(msg) >= 7 && msg[:7] == "Warning"
+}
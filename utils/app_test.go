@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder keeps the messages sent to it, failing while down
+type recorder struct {
+	name string
+	down bool
+
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Notify(msg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return errors.New(r.name + " is down")
+	}
+	// Drop the correlation ID, which is random
+	lines := slices.DeleteFunc(strings.Split(msg, "\n"), func(line string) bool {
+		return strings.HasPrefix(line, "Alert #")
+	})
+	r.sent = append(r.sent, strings.Join(lines, "\n"))
+	return nil
+}
+
+func (r *recorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.sent)
+}
+
+var (
+	normalAlert   = Alert{Message: "Remaining electricity: 80.00", Public: "Room 101 electricity is fine", Rule: "normal", Level: LevelNormal}
+	warningAlert  = Alert{Message: "Warning: Remaining electricity is low: 10.00", Public: "Warning: Room 101 is running low", Rule: "low", Level: LevelWarning}
+	criticalAlert = Alert{Message: "Warning: Exceeded limit by 2.00!", Public: "Warning: Room 101 has exceeded its limit", Rule: "exceeded", Level: LevelCritical}
+	privateAlert  = Alert{Message: "Warning: Remaining electricity is low: 10.00", Rule: "low", Level: LevelWarning}
+)
+
+func TestNotifyRouting(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert Alert
+		want  map[string][]string
+	}{
+		{"normal", normalAlert, map[string][]string{
+			"all":    {normalAlert.Message},
+			"public": {normalAlert.Public},
+		}},
+		{"warning", warningAlert, map[string][]string{
+			"all":      {warningAlert.Message},
+			"warnings": {warningAlert.Message},
+			"public":   {warningAlert.Public},
+		}},
+		{"critical", criticalAlert, map[string][]string{
+			"all":      {criticalAlert.Message},
+			"warnings": {criticalAlert.Message},
+			"critical": {criticalAlert.Message},
+			"public":   {criticalAlert.Public},
+		}},
+		{"without public rendering", privateAlert, map[string][]string{
+			"all":      {privateAlert.Message},
+			"warnings": {privateAlert.Message},
+		}},
+		{"targeted", Alert{Message: warningAlert.Message, Level: LevelWarning, Channels: []string{"Warnings"}}, map[string][]string{
+			"warnings": {warningAlert.Message},
+		}},
+	}
+	for _, tt := range tests {
+		all, warnings, critical, public := &recorder{name: "all"}, &recorder{name: "warnings"}, &recorder{name: "critical"}, &recorder{name: "public"}
+		a := &App{Clock: &FakeClock{T: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}, Channels: []Channel{
+			{Notifier: all},
+			{Notifier: warnings, WarningsOnly: true},
+			{Notifier: critical, CriticalOnly: true},
+			{Notifier: public, Public: true},
+		}}
+		if err := a.Notify(tt.alert); err != nil {
+			t.Errorf("%s: Notify: %v", tt.name, err)
+		}
+		for _, r := range []*recorder{all, warnings, critical, public} {
+			if got := r.messages(); !slices.Equal(got, tt.want[r.name]) {
+				t.Errorf("%s: channel %s got %q, want %q", tt.name, r.name, got, tt.want[r.name])
+			}
+		}
+	}
+}
+
+func TestNotifyFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		down    []string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"first succeeds", nil, map[string]int{"sms": 1}, false},
+		{"falls back", []string{"sms"}, map[string]int{"email": 1}, false},
+		{"all down", []string{"sms", "email"}, map[string]int{}, true},
+	}
+	for _, tt := range tests {
+		sms, email, other := &recorder{name: "sms"}, &recorder{name: "email"}, &recorder{name: "other"}
+		for _, r := range []*recorder{sms, email} {
+			r.down = slices.Contains(tt.down, r.name)
+		}
+		a := &App{
+			Clock:    &FakeClock{T: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+			Channels: []Channel{{Notifier: other}, {Notifier: email}, {Notifier: sms}},
+			Delivery: Delivery{Mode: FallbackMode, Chain: []string{"sms", "email"}},
+		}
+		err := a.Notify(warningAlert)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Notify error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		// Channels outside the chain get nothing
+		for _, r := range []*recorder{sms, email, other} {
+			if got := len(r.messages()); got != tt.want[r.name] {
+				t.Errorf("%s: channel %s got %d messages, want %d", tt.name, r.name, got, tt.want[r.name])
+			}
+		}
+	}
+}
+
+func TestFetchFailureNotice(t *testing.T) {
+	fetcher, err := NewFakeFetcher(FakeScheme + "?fail=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, critical, public, sms, email := &recorder{name: "all"}, &recorder{name: "critical"}, &recorder{name: "public"}, &recorder{name: "sms", down: true}, &recorder{name: "email"}
+	a := &App{
+		Fetcher:  fetcher,
+		Store:    NopStore{},
+		Clock:    &FakeClock{T: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+		Channels: []Channel{{Notifier: all}, {Notifier: critical, CriticalOnly: true}, {Notifier: public, Public: true}, {Notifier: sms}, {Notifier: email}},
+		Delivery: Delivery{Mode: FallbackMode, Chain: []string{"critical", "public", "sms", "email"}},
+		Policy:   Policy{Failure: NeverFail},
+	}
+	a.MaxRetries = 1
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// Critical-only and public channels are skipped, the failing SMS
+	// falls back to email, and channels off the chain get nothing
+	if got := email.messages(); len(got) != 1 || !strings.HasPrefix(got[0], ErrMaxRetries.Error()) {
+		t.Errorf("email got %q, want the fetch failure", got)
+	}
+	for _, r := range []*recorder{all, critical, public, sms} {
+		if got := r.messages(); len(got) != 0 {
+			t.Errorf("channel %s got %q, want nothing", r.name, got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Away configures vacation mode: checks become less frequent, routine
+// reports are dropped and any real consumption raises a warning
+type Away struct {
+	Enabled        bool    // away regardless of dates
+	From           string  // start of an away period, e.g. 2025-01-10
+	Until          string  // end of the away period (inclusive day)
+	Interval       int     `example:"360"` // minutes between checks while away, defaults to 360
+	MinConsumption float64 `example:"0.5"` // units used since the last check that count as activity, defaults to 0.5
+}
+
+// CommandAway switches away mode from the bot: /away on, /away off,
+// /away until 2025-02-01 or /away 2025-01-10 2025-02-01; /away alone
+// shows the current state
+const CommandAway = "/away"
+
+// errAwayStore explains why away mode is refused without a history
+var errAwayStore = errors.New("away mode needs Store.File or Store.SQLite, the readings it compares")
+
+// configured reports whether away mode is switched on or has a period
+func (A *Away) configured() bool {
+	return A.Enabled || A.From != "" || A.Until != ""
+}
+
+// Validate checks the period, and that readings are kept: the reduced
+// checks and the activity warning both compare against the history
+func (A *Away) Validate(store StoreConfig) error {
+	if !A.configured() {
+		return nil
+	}
+	if store.File == "" && store.SQLite == "" {
+		return errAwayStore
+	}
+	return A.checkPeriod()
+}
+
+// checkPeriod checks the dates of the away period
+func (A *Away) checkPeriod() error {
+	for _, date := range []string{A.From, A.Until} {
+		if date == "" {
+			continue
+		}
+		if _, err := ParseTime(date); err != nil {
+			return fmt.Errorf("invalid away period: %w", err)
+		}
+	}
+	return nil
+}
+
+// Active reports whether away mode applies at t
+func (A *Away) Active(t time.Time) bool {
+	if A.Enabled {
+		return true
+	}
+	if A.From == "" && A.Until == "" {
+		return false
+	}
+	if A.From != "" {
+		from, err := ParseTimeAt(A.From, t)
+		if err != nil || t.Before(from) {
+			return false
+		}
+	}
+	if A.Until != "" {
+		until, err := ParseTimeAt(A.Until, t)
+		if err != nil {
+			return false
+		}
+		if len(A.Until) == len("2006-01-02") {
+			until = until.AddDate(0, 0, 1)
+		}
+		if !t.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// away reports whether away mode applies at t, either configured
+// directly or because the calendar expects the room to be empty
+func (a *App) away(t time.Time) bool {
+	return a.Away.Active(t) || a.Calendar.Empty(t)
+}
+
+// interval is the time between checks while away
+func (A *Away) interval() time.Duration {
+	if A.Interval <= 0 {
+		return 6 * time.Hour
+	}
+	return time.Duration(A.Interval) * time.Minute
+}
+
+// awaySkip reports whether this run falls inside the reduced away
+// frequency, i.e. a reading was already taken within the interval
+func (a *App) awaySkip() bool {
+	now := a.Clock.Now()
+	if !a.away(now) {
+		return false
+	}
+	recent, err := a.Store.History(now.Add(-a.Away.interval()))
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+		return false
+	}
+	return len(recent) > 0
+}
+
+// applyAway rewrites the alert for away mode. It returns false when the
+// alert is a routine report that should be dropped.
+func (a *App) applyAway(alert *Alert) bool {
+	r := alert.Reading
+	if !a.away(r.Timestamp) {
+		return true
+	}
+	minimum := a.Away.MinConsumption
+	if minimum <= 0 {
+		minimum = 0.5
+	}
+	if prev, ok := a.previous(r); ok && r.Used-prev.Used >= minimum {
+		alert.Message = fmt.Sprintf("Warning: %.2f used since %s while you are away, is something left on?\n%s",
+			r.Used-prev.Used, a.Locale.DateTime(prev.Timestamp), alert.Message)
+		alert.Channels = nil
+		if !alert.IsWarning() {
+			alert.Level = LevelWarning
+		}
+		return true
+	}
+	return alert.IsWarning()
+}
+
+// previous returns the last stored reading taken before r
+func (a *App) previous(r Reading) (Reading, bool) {
+	history, err := a.Store.History(r.Timestamp.AddDate(0, 0, -30))
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+		return Reading{}, false
+	}
+	return lastBefore(history, r)
+}
+
+// awayOverride is away mode as switched by /away, kept in State with
+// the settings and laid over the config file
+type awayOverride struct {
+	Enabled     bool
+	From, Until string
+}
+
+// handleAway carries out /away with its arguments
+func (a *App) handleAway(args []string) (string, error) {
+	if len(args) == 0 {
+		return a.awayStatus(), nil
+	}
+	if a.State == nil || a.State.path == "" {
+		return "", fmt.Errorf("away mode from the bot needs State.File to be kept")
+	}
+	if _, ok := a.Store.(NopStore); ok {
+		return "", errAwayStore
+	}
+	var next awayOverride
+	switch {
+	case len(args) == 1 && args[0] == "on":
+		next.Enabled = true
+	case len(args) == 1 && args[0] == "off":
+	case len(args) == 2 && args[0] == "until":
+		next.Until = args[1]
+	case len(args) == 2:
+		next.From, next.Until = args[0], args[1]
+	default:
+		return "", fmt.Errorf("usage: %s on, %s off, %s until DATE or %s FROM UNTIL", CommandAway, CommandAway, CommandAway, CommandAway)
+	}
+	away := a.Away
+	away.Enabled, away.From, away.Until = next.Enabled, next.From, next.Until
+	if err := away.checkPeriod(); err != nil {
+		return "", err
+	}
+
+	var o settingsOverrides
+	if _, err := a.State.Get(settingsKey, &o); err != nil {
+		return "", err
+	}
+	o.Away = &next
+	if err := a.State.Put(settingsKey, o); err != nil {
+		return "", err
+	}
+	a.Away = away
+	return a.awayStatus(), nil
+}
+
+// awayStatus describes the current away mode for the chat
+func (a *App) awayStatus() string {
+	A := a.Away
+	switch {
+	case A.Enabled:
+		return "Away mode is on until " + CommandAway + " off"
+	case A.From == "" && A.Until == "":
+		return "Away mode is off"
+	}
+	state := "off"
+	if A.Active(a.Clock.Now()) {
+		state = "on"
+	}
+	period := "from " + A.From + " until " + A.Until
+	if A.From == "" {
+		period = "until " + A.Until
+	} else if A.Until == "" {
+		period = "from " + A.From
+	}
+	return fmt.Sprintf("Away mode is %s, away %s", state, period)
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// Backfiller is a fetcher that can also return past readings
+type Backfiller interface {
+	Backfill(since, until time.Time) ([]Reading, error)
+}
+
+// Backfill configures the import of past readings on the first run
+type Backfill struct {
+	Days     int `example:"30"` // how far back to import, defaults to 30
+	Disabled bool
+}
+
+// backfill imports past readings when the store has no recent history
+// and the fetcher can provide them, so forecasts work from day one
+func (a *App) backfill() error {
+	b, ok := a.Fetcher.(Backfiller)
+	if _, nop := a.Store.(NopStore); !ok || nop || a.Backfill.Disabled {
+		return nil
+	}
+	days := a.Backfill.Days
+	if days <= 0 {
+		days = 30
+	}
+	now := a.Clock.Now()
+	since := now.AddDate(0, 0, -days)
+	history, err := a.Store.History(since)
+	if err != nil || len(history) > 0 {
+		return err
+	}
+
+	past, err := b.Backfill(since, now)
+	if err != nil {
+		return fmt.Errorf("failed to backfill history: %w", err)
+	}
+	for _, r := range past {
+		if err := a.Store.Save(r); err != nil {
+			return err
+		}
+	}
+	if len(past) > 0 {
+		fmt.Printf("Backfilled %d past readings\n", len(past))
+	}
+	return nil
+}
+
+// Backfill asks HistoryAPI for the daily readings between two dates. The
+// endpoint receives the usual payload plus startDate and endDate and
+// answers {"data": [{"date": "2006-01-02", "usedAmp": ..., "allAmp": ...}]}.
+func (R *RequestData) Backfill(since, until time.Time) ([]Reading, error) {
+	if R.HistoryAPI == "" {
+		return nil, nil
+	}
+	payload := R.payload()
+	payload["startDate"] = since.Format("2006-01-02")
+	payload["endDate"] = until.Format("2006-01-02")
+
+	var res struct {
+		Data []struct {
+			Date    string  `json:"date"`
+			UsedAmp float64 `json:"usedAmp"`
+			AllAmp  float64 `json:"allAmp"`
+		} `json:"data"`
+	}
+	if err := R.post(R.HistoryAPI, payload, &res); err != nil {
+		return nil, err
+	}
+	var readings []Reading
+	for _, d := range res.Data {
+		t, err := time.ParseInLocation("2006-01-02", d.Date, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history date %q", d.Date)
+		}
+		r := NewReading(d.UsedAmp, d.AllAmp, R.Room)
+		r.Timestamp = t
+		readings = append(readings, r)
+	}
+	return readings, nil
+}
+
+// Backfill asks a plugin with the history role for past readings
+func (p *Plugin) Backfill(since, until time.Time) ([]Reading, error) {
+	if !p.Has("history") {
+		return nil, nil
+	}
+	res, err := p.call(pluginRequest{Kind: "history", Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+	var readings []Reading
+	for _, h := range res.Readings {
+		r := NewReading(h.Used, h.Total, h.Room)
+		r.Timestamp = h.Time
+		readings = append(readings, r)
+	}
+	return readings, nil
+}
+
+// Backfill makes up one reading a day, step units apart, ending at the
+// fetcher's starting value on until
+func (f *FakeFetcher) Backfill(since, until time.Time) ([]Reading, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var readings []Reading
+	days := int(until.Sub(since).Hours() / 24)
+	for i := days; i > 0; i-- {
+		r := NewReading(f.used-f.step*float64(i), f.total, f.room)
+		r.Timestamp = until.AddDate(0, 0, -i)
+		readings = append(readings, r)
+	}
+	return readings, nil
+}
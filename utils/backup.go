@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BackupEntry is a file in a backup archive, restored to Path
+type BackupEntry struct {
+	Path   string // where the file lives and is restored to
+	Secret bool   `json:",omitempty"` // credentials, left out without secrets
+	Source string `json:"-"`          // read from here instead of Path, e.g. a database snapshot
+}
+
+// BackupManifest describes a backup archive
+type BackupManifest struct {
+	Created  time.Time
+	Config   string // name of the config file when backed up
+	Redacted bool   // secrets were blanked in the config and their files left out
+	Files    []BackupEntry
+}
+
+// Archive member names besides the files
+const (
+	manifestMember = "manifest.json"
+	configMember   = "config"
+)
+
+// secretSuffixes mark the config keys blanked in a backup without
+// secrets, e.g. BotToken, Password or DeviceKey
+var secretSuffixes = []string{"Token", "Password", "Secret", "Key", "Passphrase", "Authorization", "WebhookURL"}
+
+// BackupEntries lists the data files the configuration refers to: the
+// history, the smaller files kept next to it, and the state with API
+// tokens, queue and acknowledgements. The state and credential files
+// are marked secret.
+func (c *Config) BackupEntries() []BackupEntry {
+	var entries []BackupEntry
+	add := func(secret bool, paths ...string) {
+		for _, p := range paths {
+			if p != "" && !IsFake(p) {
+				entries = append(entries, BackupEntry{Path: p, Secret: secret})
+			}
+		}
+	}
+	add(false, c.Store.File, c.Store.SQLite, c.Snooze.File, c.SubMeters.File,
+		c.Audit.File, c.Summary.File, c.Webhooks.DeadLetter, c.Tips.File, c.Hooks.Script, c.Email.HTMLTemplate)
+	for _, room := range c.Rooms {
+		add(false, room.Store.File)
+	}
+	add(true, c.State.File, c.Email.CredentialsFile, c.Email.TokenFile, c.RequestData.ClientCert,
+		c.RequestData.ClientKey, c.Encryption.IdentityFile)
+	return entries
+}
+
+// RedactConfig blanks the secrets in a JSON or YAML config, keeping the
+// keys so the restored file shows what to fill in, and drops the
+// credentials embedded in URLs such as a proxy's
+func RedactConfig(b []byte, isYAML bool) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	doc = redact(doc)
+	if isYAML {
+		return yaml.Marshal(doc)
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	err := enc.Encode(doc)
+	return out.Bytes(), err
+}
+
+// redact blanks the string values under secret keys, including headers
+// such as Authorization, and the user info of URLs anywhere
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if u, err := url.Parse(v); err == nil && u.Host != "" && u.User != nil {
+			u.User = nil
+			return u.String()
+		}
+	case map[string]interface{}:
+		for k, child := range v {
+			if _, ok := child.(string); ok && isSecretKey(k) {
+				v[k] = ""
+			} else {
+				v[k] = redact(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redact(child)
+		}
+	}
+	return v
+}
+
+// isSecretKey reports whether a config key holds a secret
+func isSecretKey(k string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBackup writes a gzipped tar archive of the manifest, the config
+// and the manifest's files. Missing files are dropped from the manifest.
+func WriteBackup(w io.Writer, m BackupManifest, config []byte) (BackupManifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	type member struct {
+		name string
+		data []byte
+		mode int64
+	}
+	members := []member{{configMember, config, 0600}}
+	var files []BackupEntry
+	for _, e := range m.Files {
+		src := e.Source
+		if src == "" {
+			src = e.Path
+		}
+		info, err := os.Stat(src)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return m, err
+		}
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return m, fmt.Errorf("failed to back up %s: %w", e.Path, err)
+		}
+		members = append(members, member{fileMember(len(files)), b, int64(info.Mode().Perm())})
+		files = append(files, e)
+	}
+	m.Files = files
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	members = append([]member{{manifestMember, manifest, 0644}}, members...)
+
+	for _, mem := range members {
+		hdr := &tar.Header{Name: mem.name, Mode: mem.mode, Size: int64(len(mem.data)), ModTime: m.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return m, err
+		}
+		if _, err := tw.Write(mem.data); err != nil {
+			return m, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return m, err
+	}
+	return m, gz.Close()
+}
+
+// fileMember names the i-th file of the manifest in the archive
+func fileMember(i int) string {
+	return fmt.Sprintf("files/%d", i)
+}
+
+// RestorablePath reports whether a file can be restored from a backup:
+// restores only write below the working directory, so an archive cannot
+// overwrite files elsewhere
+func RestorablePath(path string) bool {
+	return filepath.IsLocal(path) && filepath.Clean(path) == filepath.FromSlash(path)
+}
+
+// RestoreBackup writes the config of an archive to configPath and its
+// files back to their paths. Nothing is written when a target exists,
+// unless force is set.
+func RestoreBackup(r io.Reader, configPath string, force bool) (BackupManifest, error) {
+	var m BackupManifest
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return m, fmt.Errorf("not a backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	members := map[string][]byte{}
+	modes := map[string]os.FileMode{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, fmt.Errorf("failed to read backup: %w", err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return m, fmt.Errorf("failed to read backup: %w", err)
+		}
+		members[hdr.Name], modes[hdr.Name] = b, os.FileMode(hdr.Mode).Perm()
+	}
+	manifest, ok := members[manifestMember]
+	if !ok {
+		return m, errors.New("not a backup archive: no manifest")
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return m, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+
+	targets := map[string]string{configPath: configMember}
+	for i, e := range m.Files {
+		if !RestorablePath(e.Path) {
+			return m, fmt.Errorf("backup names %q, only clean relative paths inside the working directory are restored", e.Path)
+		}
+		targets[e.Path] = fileMember(i)
+	}
+	for path, name := range targets {
+		if _, ok := members[name]; !ok {
+			return m, fmt.Errorf("backup is missing %s", path)
+		}
+		if _, err := os.Stat(path); err == nil && !force {
+			return m, fmt.Errorf("%s already exists, restore with -force to overwrite it", path)
+		}
+	}
+	for path, name := range targets {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return m, err
+			}
+		}
+		if err := os.WriteFile(path, members[name], modes[name]); err != nil {
+			return m, fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bark pushes alerts to an iPhone through a Bark server
+type Bark struct {
+	Server       string `example:"https://api.day.app"` // defaults to https://api.day.app
+	DeviceKey    string
+	Sound        string // e.g. "alarm", the default sound when empty
+	Group        string `example:"electricity"` // groups the notifications in Notification Center
+	WarningsOnly bool
+}
+
+// Name identifies the Bark channel
+func (B *Bark) Name() string { return "Bark" }
+
+// Notify implements Notifier for messages without an alert
+func (B *Bark) Notify(msg string) error { return B.push(msg, IsWarning(msg)) }
+
+// NotifyAlert implements AlertNotifier, ringing by the alert's level
+// rather than its message
+func (B *Bark) NotifyAlert(alert Alert, msg string) error {
+	return B.push(msg, alert.IsWarning())
+}
+
+// push sends msg to the device. Warnings use the critical level, which
+// rings even in silent mode and Focus.
+func (B *Bark) push(msg string, warning bool) error {
+	server := strings.TrimRight(B.Server, "/")
+	if server == "" {
+		server = "https://api.day.app"
+	}
+	title, body, _ := strings.Cut(msg, "\n")
+	push := map[string]interface{}{
+		"device_key": B.DeviceKey,
+		"title":      title,
+		"body":       body,
+		"level":      "active",
+	}
+	if warning {
+		push["level"] = "critical"
+		push["volume"] = 5
+	}
+	if B.Sound != "" {
+		push["sound"] = B.Sound
+	}
+	if B.Group != "" {
+		push["group"] = B.Group
+	}
+
+	var res struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := postJSON(server+"/push", nil, push, &res); err != nil {
+		return fmt.Errorf("failed to send Bark push: %w", err)
+	}
+	if res.Code != 200 {
+		return fmt.Errorf("Bark push failed: %d %s", res.Code, res.Message)
+	}
+	return nil
+}
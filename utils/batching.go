@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batching coalesces the alerts raised during one run, e.g. by several
+// rooms or a goal warning next to the regular report, into a single
+// message per channel
+type Batching struct {
+	Window int // seconds alerts are held back to be combined, 0 sends each at once
+}
+
+// notifyBatch holds the deliveries of a run until it is flushed
+type notifyBatch struct {
+	mu      sync.Mutex
+	started time.Time
+	alerts  []Alert
+	jobs    []delivery
+}
+
+// startBatch begins holding back alerts, if batching is configured
+func (a *App) startBatch() {
+	if a.Batching.Window > 0 && a.batch == nil {
+		a.batch = &notifyBatch{}
+	}
+}
+
+// hold adds the deliveries of an alert to the batch. Alerts are held at
+// most for the window, so a slow run still sends its first alerts in time.
+func (a *App) hold(alert Alert, jobs []delivery) error {
+	b := a.batch
+	b.mu.Lock()
+	if len(b.alerts) == 0 {
+		b.started = a.Clock.Now()
+	}
+	b.alerts = append(b.alerts, alert)
+	b.jobs = append(b.jobs, jobs...)
+	expired := a.Clock.Now().Sub(b.started) >= time.Duration(a.Batching.Window)*time.Second
+	b.mu.Unlock()
+	if expired {
+		return a.sendBatch()
+	}
+	return nil
+}
+
+// flushBatch sends what is held back and stops batching
+func (a *App) flushBatch() error {
+	if a.batch == nil {
+		return nil
+	}
+	err := a.sendBatch()
+	a.batch = nil
+	return err
+}
+
+// sendBatch delivers the held alerts, one combined message per channel
+// with warnings first
+func (a *App) sendBatch() error {
+	b := a.batch
+	b.mu.Lock()
+	alerts, jobs := b.alerts, b.jobs
+	b.alerts, b.jobs = nil, nil
+	b.mu.Unlock()
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].warning() && !jobs[j].warning()
+	})
+	var merged []delivery
+	for _, job := range jobs {
+		found := false
+		for i := range merged {
+			if merged[i].Notifier == job.Notifier {
+				merged[i].msg += "\n\n" + job.msg
+				merged[i].alert = nil
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, job)
+		}
+	}
+
+	combined := alerts[0]
+	if len(alerts) > 1 {
+		var messages []string
+		for _, alert := range alerts {
+			messages = append(messages, alert.Message)
+		}
+		combined.Message = strings.Join(messages, "\n\n")
+		combined.Rule = "batch"
+		// The batch is as severe as its most severe alert
+		for _, alert := range alerts {
+			if alert.IsCritical() || (alert.IsWarning() && !combined.IsWarning()) {
+				combined.Level = alert.Severity()
+			}
+		}
+	}
+	return a.deliver(combined, merged)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import "time"
+
+// Period is a named date range, both ends inclusive, e.g. 2025-01-10
+type Period struct {
+	Name  string
+	From  string
+	Until string
+}
+
+// Contains reports whether t falls on a day inside the period
+func (p Period) Contains(t time.Time) bool {
+	from, err := ParseTimeAt(p.From, t)
+	if err != nil {
+		return false
+	}
+	until, err := ParseTimeAt(p.Until, t)
+	if err != nil {
+		return false
+	}
+	return !t.Before(from) && t.Before(until.AddDate(0, 0, 1))
+}
+
+// Calendar describes when the room is occupied. Outside the terms (when
+// any are configured) and inside breaks the room is considered empty.
+type Calendar struct {
+	Terms  []Period `example:"[{Name: Fall, From: '2026-09-01', Until: '2026-12-20'}, {Name: Spring, From: '2027-01-11', Until: '2027-05-14'}]"`
+	Breaks []Period `example:"[{Name: National Day, From: '2026-10-01', Until: '2026-10-07'}]"`
+}
+
+// Empty reports whether the room is expected to be empty at t
+func (C *Calendar) Empty(t time.Time) bool {
+	for _, b := range C.Breaks {
+		if b.Contains(t) {
+			return true
+		}
+	}
+	if len(C.Terms) == 0 {
+		return false
+	}
+	for _, term := range C.Terms {
+		if term.Contains(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// OccupiedDays counts the days between from and to on which the room is
+// expected to be in use
+func (C *Calendar) OccupiedDays(from, to time.Time) float64 {
+	var days float64
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		step := 1.0
+		if next := day.AddDate(0, 0, 1); next.After(to) {
+			step = to.Sub(day).Hours() / 24
+		}
+		if !C.Empty(day) {
+			days += step
+		}
+	}
+	return days
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Photo is an image attached to a message
+type Photo struct {
+	Name string
+	PNG  []byte
+}
+
+// AlbumSender is a notifier able to send several photos as one message
+type AlbumSender interface {
+	SendAlbum(caption string, photos []Photo) error
+}
+
+// Chart draws the remaining balance over the history as a PNG line chart.
+// The dashed line marks zero when it is in range.
+func Chart(history []Reading, width, height int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	bg := color.RGBA{255, 255, 255, 255}
+	axis := color.RGBA{160, 160, 160, 255}
+	line := color.RGBA{33, 110, 220, 255}
+	low := color.RGBA{220, 50, 47, 255}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, bg)
+		}
+	}
+
+	const pad = 10
+	for x := pad; x < width-pad; x++ {
+		img.Set(x, height-pad, axis)
+	}
+	for y := pad; y < height-pad; y++ {
+		img.Set(pad, y, axis)
+	}
+	if len(history) > 1 {
+		first, last := history[0].Timestamp, history[len(history)-1].Timestamp
+		lo, hi := history[0].Remaining, history[0].Remaining
+		for _, r := range history {
+			lo, hi = min(lo, r.Remaining), max(hi, r.Remaining)
+		}
+		lo = min(lo, 0)
+		if hi == lo {
+			hi = lo + 1
+		}
+		span := last.Sub(first)
+		if span <= 0 {
+			span = time.Second
+		}
+		px := func(t time.Time) int {
+			return pad + int(float64(width-2*pad-1)*float64(t.Sub(first))/float64(span))
+		}
+		py := func(v float64) int {
+			return height - pad - int(float64(height-2*pad-1)*(v-lo)/(hi-lo))
+		}
+		if lo < 0 {
+			for x := pad; x < width-pad; x += 4 {
+				img.Set(x, py(0), axis)
+			}
+		}
+		for i := 1; i < len(history); i++ {
+			c := line
+			if history[i].Remaining <= 0 {
+				c = low
+			}
+			drawLine(img, px(history[i-1].Timestamp), py(history[i-1].Remaining),
+				px(history[i].Timestamp), py(history[i].Remaining), c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine draws a two pixel wide line between two points
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	steps := max(abs(x1-x0), abs(y1-y0), 1)
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+		img.Set(x, y, c)
+		img.Set(x, y+1, c)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// SendAlbum sends the photos to every chat as Telegram media groups of
+// up to ten, with the caption on the first photo. A single photo goes
+// out as sendPhoto.
+func (T *Telegram) SendAlbum(caption string, photos []Photo) error {
+	var errs []error
+	for _, chatID := range T.chatIDs() {
+		if err := T.sendAlbumTo(chatID, caption, photos); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendAlbumTo sends the photos to one chat
+func (T *Telegram) sendAlbumTo(chatID, caption string, photos []Photo) error {
+	if len(photos) == 1 {
+		return T.upload("sendPhoto", url.Values{"chat_id": {chatID}, "caption": {caption}},
+			map[string]Photo{"photo": photos[0]})
+	}
+	for start := 0; start < len(photos); start += 10 {
+		end := min(start+10, len(photos))
+		var media []map[string]string
+		files := map[string]Photo{}
+		for i, p := range photos[start:end] {
+			key := fmt.Sprintf("chart%d", i)
+			item := map[string]string{"type": "photo", "media": "attach://" + key}
+			if i == 0 && start == 0 {
+				item["caption"] = caption
+			}
+			media = append(media, item)
+			files[key] = p
+		}
+		b, err := json.Marshal(media)
+		if err != nil {
+			return err
+		}
+		if err := T.upload("sendMediaGroup", url.Values{"chat_id": {chatID}, "media": {string(b)}}, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upload calls a Bot API method with files as multipart form data,
+// posting into the forum topic in group chats
+func (T *Telegram) upload(method string, fields url.Values, files map[string]Photo) error {
+	T.setThread(fields, fields.Get("chat_id"))
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k := range fields {
+		w.WriteField(k, fields.Get(k))
+	}
+	for key, p := range files {
+		part, err := w.CreateFormFile(key, p.Name)
+		if err != nil {
+			return err
+		}
+		part.Write(p.PNG)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	posturl := fmt.Sprintf("https://%s/bot%s/%s", T.APIHost, T.BotToken, method)
+	resp, err := T.client().Post(posturl, w.FormDataContentType(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to call Telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	return decodeTelegram(method, resp.Body, nil)
+}
+
+// Charts renders a chart of the last days for the main room and every
+// further room with its own history, captioned with their balances
+func (a *App) Charts(days int) (string, []Photo, error) {
+	since := a.Clock.Now().AddDate(0, 0, -days)
+	rooms := []*App{a}
+	for _, room := range a.Rooms {
+		rooms = append(rooms, a.roomApp(room))
+	}
+
+	var lines []string
+	var photos []Photo
+	for i, r := range rooms {
+		label := r.Label
+		if label == "" {
+			label = fmt.Sprintf("Room %d", i+1)
+		}
+		history, err := r.Series(since)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(history) < 2 {
+			continue
+		}
+		png, err := Chart(history, 640, 320)
+		if err != nil {
+			return "", nil, err
+		}
+		photos = append(photos, Photo{Name: fmt.Sprintf("room%d.png", i+1), PNG: png})
+		lines = append(lines, fmt.Sprintf("%s: %.2f left", label, history[len(history)-1].Remaining))
+	}
+	caption := fmt.Sprintf("Electricity over the last %d days\n%s", days, strings.Join(lines, "\n"))
+	return caption, photos, nil
+}
+
+// SendAlbum delivers the photos to every private channel able to send albums
+func (a *App) SendAlbum(caption string, photos []Photo) error {
+	var errs []string
+	for _, ch := range a.Channels {
+		sender, ok := ch.Notifier.(AlbumSender)
+		if !ok || ch.Public || ch.Escalation {
+			continue
+		}
+		if err := sender.SendAlbum(caption, photos); err != nil {
+			log.Printf("Failed to send %s album: %v", ch.Name(), err)
+			errs = append(errs, ch.Name())
+		}
+	}
+	if len(errs) > 0 {
+		return &DeliveryError{Failed: errs}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock provides the current time and sleeping, so time can be faked in tests
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the Clock backed by the real time
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time        { return time.Now() }
+func (SystemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// ShiftedClock runs at real speed but starts from a chosen instant,
+// so a run can be replayed as if it happened at another time
+type ShiftedClock struct {
+	offset time.Duration
+}
+
+// NewShiftedClock returns a clock whose Now currently reports start
+func NewShiftedClock(start time.Time) *ShiftedClock {
+	return &ShiftedClock{offset: time.Until(start)}
+}
+
+func (c *ShiftedClock) Now() time.Time        { return time.Now().Add(c.offset) }
+func (c *ShiftedClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// FakeClock only moves when slept on, for fully deterministic runs
+type FakeClock struct {
+	T time.Time
+}
+
+func (c *FakeClock) Now() time.Time        { return c.T }
+func (c *FakeClock) Sleep(d time.Duration) { c.T = c.T.Add(d) }
+
+// clockLayouts are accepted by ParseTime, tried in order
+var clockLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04",
+}
+
+// ParseTime reads a user-supplied time in local time.
+// A bare clock time like 03:00 refers to today by the system clock;
+// ParseTimeAt takes the day from an app clock instead.
+func ParseTime(s string) (time.Time, error) {
+	return ParseTimeAt(s, time.Now())
+}
+
+// ParseTimeAt reads a user-supplied time in local time, a bare clock
+// time referring to the day of now
+func ParseTimeAt(s string, now time.Time) (time.Time, error) {
+	for _, layout := range clockLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == "15:04" {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
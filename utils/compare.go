@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Comparison configures the opt-in building comparison in reports
+type Comparison struct {
+	OptIn    bool // show "your room vs. building median" lines
+	MinRooms int  `example:"5"` // smallest group that is aggregated, defaults to 5
+}
+
+// RoomUsage is one room's average daily consumption.
+// Only the building is kept next to the value, so aggregates can be
+// computed without exposing which room contributed what.
+type RoomUsage struct {
+	Building string
+	Daily    float64
+}
+
+// BuildingMedians returns the median daily consumption per building.
+// Buildings with fewer than minRooms samples are left out, since their
+// median would reveal individual rooms.
+func (C Comparison) BuildingMedians(samples []RoomUsage) map[string]float64 {
+	minRooms := C.MinRooms
+	if minRooms <= 0 {
+		minRooms = 5
+	}
+	grouped := map[string][]float64{}
+	for _, s := range samples {
+		grouped[s.Building] = append(grouped[s.Building], s.Daily)
+	}
+	medians := map[string]float64{}
+	for building, values := range grouped {
+		if len(values) < minRooms {
+			continue
+		}
+		sort.Float64s(values)
+		mid := len(values) / 2
+		if len(values)%2 == 0 {
+			medians[building] = (values[mid-1] + values[mid]) / 2
+		} else {
+			medians[building] = values[mid]
+		}
+	}
+	return medians
+}
+
+// CompareLine phrases a room's consumption against its building median
+func CompareLine(own, median float64) string {
+	if median <= 0 {
+		return ""
+	}
+	pct := (own - median) / median * 100
+	switch {
+	case math.Abs(pct) < 1:
+		return "Your room uses about the same as the building median."
+	case pct > 0:
+		return fmt.Sprintf("Your room uses %.0f%% more than the building median.", pct)
+	default:
+		return fmt.Sprintf("Your room uses %.0f%% less than the building median.", -pct)
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrUnchanged reports that the API answered a conditional request with
+// 304 Not Modified
+var ErrUnchanged = errors.New("reading unchanged")
+
+// Validators identify the last response for conditional requests
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ConditionalFetcher can skip the transfer when nothing changed; it
+// returns ErrUnchanged in that case
+type ConditionalFetcher interface {
+	Fetcher
+	GetMsgIf(v Validators) (Reading, Validators, error)
+}
+
+const validatorsKey = "fetch_validators"
+
+// fetchChanged fetches like Fetch, but conditionally when the fetcher
+// supports it, returning ErrUnchanged when the reading did not change
+// since the last run. The validators are kept in State between runs.
+func (a *App) fetchChanged() (Reading, error) {
+	cf, ok := a.Fetcher.(ConditionalFetcher)
+	if !ok || a.State == nil {
+		return a.Fetch()
+	}
+	var last Validators
+	if _, err := a.State.Get(a.roomKey(validatorsKey), &last); err != nil {
+		log.Printf("Failed to read fetch validators: %v", err)
+	}
+	// A reading reused from the rate limit cache keeps the validators
+	next := last
+	reading, err := a.fetchWith(func() (Reading, error) {
+		r, v, err := cf.GetMsgIf(last)
+		if err == nil {
+			next = v
+		}
+		return r, err
+	})
+	if err != nil {
+		return reading, err
+	}
+	if next != last {
+		if err := a.State.Put(a.roomKey(validatorsKey), next); err != nil {
+			log.Printf("Failed to save fetch validators: %v", err)
+		}
+	}
+	return reading, nil
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run gen_configdoc.go
+
+// ExampleConfig builds the example configuration from the example tags
+// of the config fields, which hold a value or its default. Strings are
+// taken as they are, other values are written in YAML flow style.
+func ExampleConfig() (*Config, error) {
+	conf := &Config{}
+	if err := fillExample(reflect.ValueOf(conf).Elem()); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// fillExample sets the fields of a struct to their examples, descending
+// into nested structs without one and leaving other lists and maps empty
+func fillExample(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, field := t.Field(i), v.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		example, ok := f.Tag.Lookup("example")
+		switch {
+		case ok && field.Kind() == reflect.String:
+			field.SetString(example)
+		case ok:
+			// YAML is converted to JSON so that it decodes as configs do
+			var doc interface{}
+			err := yaml.Unmarshal([]byte(example), &doc)
+			if err == nil {
+				var b []byte
+				if b, err = json.Marshal(doc); err == nil {
+					err = json.Unmarshal(b, field.Addr().Interface())
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("invalid example of %s.%s: %w", t.Name(), f.Name, err)
+			}
+		case field.Kind() == reflect.Struct:
+			if err := fillExample(field); err != nil {
+				return err
+			}
+		// Empty rather than null in the JSON example
+		case field.Kind() == reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		case field.Kind() == reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+	}
+	return nil
+}
+
+// ExampleYAML renders conf as YAML, commented with the field and type
+// comments from the source, as collected by go generate
+func ExampleYAML(conf *Config) ([]byte, error) {
+	root := yamlNode(reflect.ValueOf(conf).Elem(), configDocs)
+	root.HeadComment = "CUHKSZ-Electricity configuration, generated by `config example`"
+	b, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// yamlNode converts a value to a YAML node, commenting struct fields
+func yamlNode(v reflect.Value, docs map[string]string) *yaml.Node {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		return yamlNode(v.Elem(), docs)
+	case reflect.Struct:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			value := yamlNode(v.Field(i), docs)
+			// Embedded structs are inlined, as encoding/json does
+			if f.Anonymous && value.Kind == yaml.MappingNode {
+				n.Content = append(n.Content, value.Content...)
+				continue
+			}
+			key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}
+			doc := docs[t.Name()+"."+f.Name]
+			if doc == "" && value.Kind == yaml.MappingNode {
+				doc = docs[indirect(f.Type).Name()]
+			}
+			switch {
+			case value.Kind == yaml.ScalarNode:
+				key.LineComment = doc
+			case value.Style&yaml.FlowStyle != 0:
+				value.LineComment = doc
+			default:
+				key.HeadComment = doc
+			}
+			n.Content = append(n.Content, key, value)
+		}
+		return n
+	case reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(k)}, yamlNode(v.MapIndex(k), docs))
+		}
+		if len(keys) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case reflect.Slice, reflect.Array:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			n.Content = append(n.Content, yamlNode(v.Index(i), docs))
+		}
+		if v.Len() == 0 || v.Type().Elem().Kind() != reflect.Struct {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case reflect.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.String()}
+	case reflect.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(v.Uint(), 10)}
+	case reflect.Float32, reflect.Float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v.Interface())}
+}
+
+// indirect strips pointers from a type
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExampleConfig(t *testing.T) {
+	conf, err := ExampleConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Telegram.APIHost != "api.telegram.org" || conf.Webhooks.Retries != 2 || len(conf.Delivery.Chain) != 2 {
+		t.Errorf("example tags not applied: %+v", conf)
+	}
+
+	// The commented YAML reads back as the same configuration
+	b, err := ExampleYAML(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("example YAML does not load: %v\n%s", err, b)
+	}
+	want, _ := json.Marshal(conf)
+	got, _ := json.Marshal(read)
+	if string(got) != string(want) {
+		t.Errorf("example YAML reads back as\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Pricing turns consumed units into money. With Tiers set, each tier's
+// rate applies to the monthly units falling into its bracket; otherwise
+// the flat Rate applies.
+type Pricing struct {
+	Currency string  `example:"¥"`                                                                         // symbol used in messages, defaults to ¥
+	Rate     float64 `example:"0.62"`                                                                      // flat price per unit
+	Tiers    []Tier  `example:"[{UpTo: 200, Rate: 0.62}, {UpTo: 400, Rate: 0.67}, {UpTo: 0, Rate: 0.92}]"` // ascending brackets, overriding Rate
+}
+
+// Tier prices the units of a month up to UpTo (cumulative); the last
+// tier may leave UpTo at 0 to cover everything above
+type Tier struct {
+	UpTo float64
+	Rate float64
+}
+
+// Enabled reports whether any price is configured
+func (P *Pricing) Enabled() bool {
+	return P.Rate > 0 || len(P.Tiers) > 0
+}
+
+// Cost prices the units consumed within one billing month
+func (P *Pricing) Cost(units float64) float64 {
+	if len(P.Tiers) == 0 {
+		return units * P.Rate
+	}
+	var cost, lower float64
+	for _, t := range P.Tiers {
+		upper := t.UpTo
+		if upper <= 0 {
+			upper = math.Inf(1)
+		}
+		if units > lower {
+			cost += (math.Min(units, upper) - lower) * t.Rate
+		}
+		if units <= upper {
+			return cost
+		}
+		lower = upper
+	}
+	// Units beyond the last bracket keep its rate
+	return cost + (units-lower)*P.Tiers[len(P.Tiers)-1].Rate
+}
+
+// Format renders an amount of money
+func (P *Pricing) Format(amount float64) string {
+	currency := P.Currency
+	if currency == "" {
+		currency = "¥"
+	}
+	return fmt.Sprintf("%s%.2f", currency, amount)
+}
+
+// MonthCost prices the units used so far this month and projects the
+// whole month from the past week's consumption rate
+func (a *App) MonthCost() (soFar, projected float64, err error) {
+	now := a.Clock.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 1, 0)
+
+	history, err := a.Store.History(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	var used float64
+	for _, d := range DailyUsage(history) {
+		used += d.Used
+	}
+
+	projectedUnits := used
+	week, err := a.Store.History(now.AddDate(0, 0, -7))
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(week) > 0 {
+		if rate, ok := ConsumptionRate(week, week[len(week)-1]); ok {
+			projectedUnits += rate * a.Calendar.OccupiedDays(now, end)
+		}
+	}
+	return a.Pricing.Cost(used), a.Pricing.Cost(projectedUnits), nil
+}
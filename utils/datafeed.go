@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DataFeed receives every reading as JSON, whether or not an alert
+// fires, so that downstream systems get the raw data
+type DataFeed struct {
+	URL     string
+	Secret  string            // signs the body with HMAC-SHA256 in X-Signature-256
+	Headers map[string]string // sent with every request, e.g. Authorization
+}
+
+// DataFeeds are the configured data-feed webhooks
+type DataFeeds []DataFeed
+
+// signatureHeader carries "sha256=" and the hex HMAC of the body
+const signatureHeader = "X-Signature-256"
+
+// feedPayload is the JSON body posted to a data feed
+type feedPayload struct {
+	Reading
+	Label string `json:"label,omitempty"` // room name when several rooms are monitored
+}
+
+// shareReading hands a fresh reading to the data feeds and the MQTT
+// broker, whether or not an alert fires
+func (a *App) shareReading(r Reading, label string) {
+	a.DataFeeds.push(r, label)
+	a.MQTT.publish(r, label)
+}
+
+// push posts the reading to every feed; failures are logged and do not
+// affect the run
+func (feeds DataFeeds) push(r Reading, label string) {
+	if len(feeds) == 0 {
+		return
+	}
+	body, err := json.Marshal(feedPayload{Reading: r, Label: label})
+	if err != nil {
+		log.Printf("Failed to encode reading for data feeds: %v", err)
+		return
+	}
+	for _, f := range feeds {
+		if f.URL == "" {
+			continue
+		}
+		if err := f.post(body); err != nil {
+			log.Printf("Failed to push reading to data feed %s: %v", f.URL, err)
+		}
+	}
+}
+
+// post sends the body, signed when a secret is configured
+func (f DataFeed) post(body []byte) error {
+	req, err := http.NewRequest("POST", f.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range f.Headers {
+		req.Header.Set(key, value)
+	}
+	if f.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(f.Secret))
+		mac.Write(body)
+		req.Header.Set(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	return nil
+}
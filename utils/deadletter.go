@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Webhooks configures how the webhook channels (Slack, WeCom, DingTalk
+// and the like) retry. The retries share the channel's NotifyTimeout.
+// Messages still failing afterwards, and not queued for the next run,
+// are written to the dead-letter file, from where `redeliver` sends them
+// again.
+type Webhooks struct {
+	Retries    int    `example:"2"` // further attempts after a failure, defaults to 2
+	Backoff    int    `example:"1"` // seconds before the first retry, doubling after each, defaults to 1
+	DeadLetter string // JSON lines file of undeliverable messages, none kept when empty
+}
+
+// DeadLetter is a message that could not be delivered
+type DeadLetter struct {
+	Channel string    `json:"channel"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error"`
+}
+
+// Wrap adds retries and the dead-letter file to a webhook notifier
+func (W Webhooks) Wrap(n Notifier) Notifier {
+	return &retrying{Notifier: n, policy: W}
+}
+
+// retrying retries a notifier with exponential backoff. It leaves
+// filing failed messages to the app, which queues or dead-letters each
+// of them once.
+type retrying struct {
+	Notifier
+	policy Webhooks
+}
+
+// Notify delivers msg, retrying before giving up on it
+func (r *retrying) Notify(msg string) error {
+	return r.notifyBy(time.Time{}, nil, msg)
+}
+
+// NotifyAlert passes the alert on to notifiers that want it
+func (r *retrying) NotifyAlert(alert Alert, msg string) error {
+	return r.notifyBy(time.Time{}, &alert, msg)
+}
+
+// notifyBy delivers msg, with alert to notifiers that want it, retrying
+// only while the next attempt can start before deadline; a zero deadline
+// allows every retry
+func (r *retrying) notifyBy(deadline time.Time, alert *Alert, msg string) error {
+	send := func() error { return r.Notifier.Notify(msg) }
+	if n, ok := r.Notifier.(AlertNotifier); ok && alert != nil {
+		send = func() error { return n.NotifyAlert(*alert, msg) }
+	}
+	retries, backoff := r.policy.Retries, time.Duration(r.policy.Backoff)*time.Second
+	if retries <= 0 {
+		retries = 2
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	err := send()
+	for i := 0; err != nil && i < retries; i++ {
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			log.Printf("%s delivery failed, no time left to retry: %v", r.Name(), err)
+			break
+		}
+		log.Printf("%s delivery failed, retrying in %s: %v", r.Name(), backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = send()
+	}
+	return err
+}
+
+// deadLetter files the failed jobs of channels keeping dead letters
+func (a *App) deadLetter(jobs []delivery) {
+	for _, job := range jobs {
+		r, ok := job.Notifier.(*retrying)
+		if !ok || r.policy.DeadLetter == "" || job.err == nil {
+			continue
+		}
+		letter := DeadLetter{Channel: job.Name(), Message: job.msg, Time: a.Clock.Now(), Error: job.err.Error()}
+		if err := r.policy.appendLetters([]DeadLetter{letter}); err != nil {
+			log.Printf("Failed to write dead letter: %v", err)
+		}
+	}
+}
+
+// lockLetters takes the file lock that guards the dead-letter file
+// across goroutines and processes and returns its release
+func (W Webhooks) lockLetters() (func(), error) {
+	f, err := os.OpenFile(W.DeadLetter+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock dead letters: %w", err)
+	}
+	if err := lockFile(f, true); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock dead letters: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// appendLetters adds letters to the dead-letter file
+func (W Webhooks) appendLetters(letters []DeadLetter) error {
+	unlock, err := W.lockLetters()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(W.DeadLetter, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, l := range letters {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadLetters reads the dead-letter file
+func (W Webhooks) DeadLetters() ([]DeadLetter, error) {
+	b, err := os.ReadFile(W.DeadLetter)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var l DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("corrupt dead letter: %w", err)
+		}
+		letters = append(letters, l)
+	}
+	return letters, scanner.Err()
+}
+
+// replaceLetters atomically replaces the dead-letter file with letters,
+// removing it when none are left; the caller holds the lock
+func (W Webhooks) replaceLetters(letters []DeadLetter) error {
+	if len(letters) == 0 {
+		if err := os.Remove(W.DeadLetter); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, l := range letters {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+	tmp := W.DeadLetter + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, W.DeadLetter)
+}
+
+// Redeliver sends every dead letter again through its channel, once and
+// without further retries, and keeps only those that still fail. It
+// holds the file lock throughout, so letters filed meanwhile by another
+// run wait for it instead of being lost.
+func (a *App) Redeliver(w Webhooks) (sent, failed int, err error) {
+	if w.DeadLetter == "" {
+		return 0, 0, fmt.Errorf("no Webhooks.DeadLetter file configured")
+	}
+	unlock, err := w.lockLetters()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+	letters, err := w.DeadLetters()
+	if err != nil {
+		return 0, 0, err
+	}
+	var remaining []DeadLetter
+	for _, l := range letters {
+		ch, ok := a.channel(l.Channel)
+		if !ok {
+			log.Printf("Channel %s is no longer configured, keeping its dead letter", l.Channel)
+			remaining = append(remaining, l)
+			continue
+		}
+		// Go around the retrying wrapper, which would add a new letter
+		n := ch.Notifier
+		if r, ok := n.(*retrying); ok {
+			n = r.Notifier
+		}
+		if err := n.Notify(l.Message); err != nil {
+			log.Printf("Redelivery through %s failed: %v", l.Channel, err)
+			l.Error = err.Error()
+			remaining = append(remaining, l)
+			continue
+		}
+		fmt.Printf("Redelivered to %s: %s\n", l.Channel, l.Message)
+		sent++
+	}
+	return sent, len(remaining), w.replaceLetters(remaining)
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// diffLookback is how far before the start a reading is looked for
+const diffLookback = 7 * 24 * time.Hour
+
+// Diff is the consumption between two points in time, measured between
+// the readings closest to them
+type Diff struct {
+	From  Reading `json:"from"` // last reading at or before the start, or the first after it
+	To    Reading `json:"to"`   // last reading at or before the end
+	Used  float64 `json:"used"`
+	Cost  string  `json:"cost,omitempty"` // formatted price of Used, only with Pricing
+	Steps int     `json:"steps"`          // readings spanned
+}
+
+// Diff reports how much was used between from and to. Top-ups do not
+// count, and a reset of the counter in between is skipped over.
+func (a *App) Diff(from, to time.Time) (Diff, error) {
+	var d Diff
+	if !to.After(from) {
+		return d, errors.New("the end must be after the start")
+	}
+	history, err := a.Store.History(from.Add(-diffLookback))
+	if err != nil {
+		return d, err
+	}
+	first, last := -1, -1
+	for i, r := range history {
+		if !r.Timestamp.After(from) || first < 0 {
+			first = i
+		}
+		if !r.Timestamp.After(to) {
+			last = i
+		}
+	}
+	if first < 0 || last <= first {
+		return d, fmt.Errorf("not enough readings between %s and %s",
+			from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"))
+	}
+	span := history[first : last+1]
+	for _, day := range DailyUsage(span) {
+		d.Used += day.Used
+	}
+	d.From, d.To, d.Steps = span[0], span[len(span)-1], len(span)-1
+	if a.Pricing.Enabled() {
+		d.Cost = a.Pricing.Format(a.Pricing.Cost(d.Used))
+	}
+	return d, nil
+}
+
+// String describes the difference in one sentence
+func (d Diff) String() string { return d.Format(Locale{}) }
+
+// Format describes the difference with the times written for l
+func (d Diff) Format(l Locale) string {
+	s := fmt.Sprintf("Used %.2f between %s and %s (remaining %.2f to %.2f)",
+		d.Used, l.DateTime(d.From.Timestamp), l.DateTime(d.To.Timestamp),
+		d.From.Remaining, d.To.Remaining)
+	if d.Cost != "" {
+		s += ", about " + d.Cost
+	}
+	return s + "."
+}
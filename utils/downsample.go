@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"time"
+)
+
+// Summarizer is implemented by stores that can downsample in the query
+// itself, keeping the last reading of every bucket
+type Summarizer interface {
+	Summary(since time.Time, bucket time.Duration) ([]Reading, error)
+}
+
+// Resolution picks the bucket size for charting a period: raw readings
+// up to two days, then hourly, daily and weekly aggregates
+func Resolution(span time.Duration) time.Duration {
+	switch {
+	case span <= 48*time.Hour:
+		return 0
+	case span <= 14*24*time.Hour:
+		return time.Hour
+	case span <= 120*24*time.Hour:
+		return 24 * time.Hour
+	default:
+		return 7 * 24 * time.Hour
+	}
+}
+
+// BucketOf numbers the bucket a time falls in; buckets are aligned to
+// local midnight so daily aggregates match calendar days
+func BucketOf(t time.Time, bucket time.Duration) int64 {
+	_, offset := t.Zone()
+	return (t.UnixMilli() + int64(offset)*1000) / bucket.Milliseconds()
+}
+
+// Downsample keeps the last reading of every bucket, the balance at the
+// end of each period; a zero bucket keeps every reading
+func Downsample(history []Reading, bucket time.Duration) []Reading {
+	if bucket <= 0 || len(history) == 0 {
+		return history
+	}
+	var out []Reading
+	for i, r := range history {
+		if i+1 < len(history) && BucketOf(history[i+1].Timestamp, bucket) == BucketOf(r.Timestamp, bucket) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Series returns the history since a time at a resolution suited to
+// charting it, downsampled by the store when it can
+func (a *App) Series(since time.Time) ([]Reading, error) {
+	bucket := Resolution(a.Clock.Now().Sub(since))
+	if s, ok := a.Store.(Summarizer); ok && bucket > 0 {
+		return s.Summary(since, bucket)
+	}
+	history, err := a.Store.History(since)
+	if err != nil {
+		return nil, err
+	}
+	return Downsample(history, bucket), nil
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// Encryption encrypts the history and state files at rest with age,
+// since their timestamps reveal when the room is occupied. Either a
+// passphrase or an age key file may be used; files written before
+// encryption was enabled are still read and get encrypted on the next
+// write.
+type Encryption struct {
+	Passphrase   string // falls back to $ELECTRICITY_PASSPHRASE
+	IdentityFile string // age key file (AGE-SECRET-KEY-...), e.g. from age-keygen
+}
+
+// passphraseEnv holds the passphrase when it is not in the config
+const passphraseEnv = "ELECTRICITY_PASSPHRASE"
+
+// ageHeader starts every binary age file
+var ageHeader = []byte("age-encryption.org/")
+
+func (E *Encryption) passphrase() string {
+	if E.Passphrase != "" {
+		return E.Passphrase
+	}
+	return os.Getenv(passphraseEnv)
+}
+
+// Enabled reports whether files should be encrypted
+func (E *Encryption) Enabled() bool {
+	return E != nil && (E.IdentityFile != "" || E.passphrase() != "")
+}
+
+// keys returns the recipient and identity for the configured secret
+func (E *Encryption) keys() (age.Recipient, age.Identity, error) {
+	if E.IdentityFile != "" {
+		f, err := os.Open(E.IdentityFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open age key file: %w", err)
+		}
+		defer f.Close()
+		ids, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse age key file: %w", err)
+		}
+		for _, id := range ids {
+			if x, ok := id.(*age.X25519Identity); ok {
+				return x.Recipient(), x, nil
+			}
+		}
+		return nil, nil, errors.New("age key file holds no X25519 key")
+	}
+	r, err := age.NewScryptRecipient(E.passphrase())
+	if err != nil {
+		return nil, nil, err
+	}
+	// Files are rewritten on every run, keep the key derivation quick
+	r.SetWorkFactor(15)
+	id, err := age.NewScryptIdentity(E.passphrase())
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, id, nil
+}
+
+// Seal encrypts data, or returns it as is when encryption is disabled
+func (E *Encryption) Seal(data []byte) ([]byte, error) {
+	if !E.Enabled() {
+		return data, nil
+	}
+	r, _, err := E.keys()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Open decrypts data written by Seal; unencrypted data is returned as is
+func (E *Encryption) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, ageHeader) {
+		return data, nil
+	}
+	if !E.Enabled() {
+		return nil, errors.New("file is encrypted but no Encryption passphrase or key is configured")
+	}
+	_, id, err := E.keys()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+// writeSealed encrypts data and replaces path with it atomically
+func (E *Encryption) writeSealed(path string, data []byte) error {
+	sealed, err := E.Seal(data)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// shareEncryption hands the encryption settings to the stores
+func (c *Config) shareEncryption() {
+	c.Store.enc = &c.Encryption
+	c.State.enc = &c.Encryption
+	for i := range c.Rooms {
+		c.Rooms[i].Store.enc = &c.Encryption
+	}
+}
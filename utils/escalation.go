@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Escalation configures a secondary contact, such as a parent, landlord
+// or RA, who stays out of routine alerts and is only told when the
+// balance goes negative or stays critical for too long
+type Escalation struct {
+	TelegramChatID string   // contact's chat, added as the Telegram-escalation channel
+	Channels       []string // further channels reserved for the contact
+	Critical       float64  `example:"5"`  // balance at or below which the room counts as critical, defaults to 5
+	After          int      `example:"24"` // hours the balance must stay critical before escalating, defaults to 24
+}
+
+const escalationKey = "escalation"
+
+// escalationState tracks the current critical spell
+type escalationState struct {
+	Since     time.Time // first critical reading of the spell, zero when not critical
+	Escalated bool      // the contact was told about this spell
+}
+
+// Enabled reports whether a secondary contact is configured
+func (e Escalation) Enabled() bool {
+	return e.TelegramChatID != "" || len(e.Channels) > 0
+}
+
+// Apply reserves the listed channels for escalations and adds the
+// contact's Telegram chat
+func (e Escalation) Apply(channels []Channel) []Channel {
+	for i, ch := range channels {
+		if len(e.Channels) > 0 && (Alert{Channels: e.Channels}).Targets(ch.Name()) {
+			channels[i].Escalation = true
+		}
+	}
+	if e.TelegramChatID == "" {
+		return channels
+	}
+	contact := (&Recipients{Channels: []string{"Telegram"}, TelegramChatID: e.TelegramChatID}).Select(channels)
+	if len(contact) == 0 {
+		return channels
+	}
+	ch := contact[0]
+	ch.Notifier = renamed{Notifier: ch.Notifier, name: "Telegram-escalation"}
+	ch.Escalation, ch.Critical, ch.WarningsOnly, ch.Public = true, false, false, false
+	return append(channels, ch)
+}
+
+// checkEscalation tells the secondary contact once per critical spell,
+// right away when the balance is negative and otherwise once it has
+// been critical for Escalation.After hours
+func (a *App) checkEscalation(r Reading) {
+	if !a.Escalation.Enabled() || a.State == nil {
+		return
+	}
+	critical := a.Escalation.Critical
+	if critical == 0 {
+		critical = 5
+	}
+	var st escalationState
+	if _, err := a.State.Get(a.roomKey(escalationKey), &st); err != nil {
+		log.Printf("Failed to read escalation state: %v", err)
+	}
+	before := st
+
+	if r.Remaining > critical {
+		st = escalationState{}
+	} else {
+		if st.Since.IsZero() {
+			st.Since = r.Timestamp
+		}
+		spell := r.Timestamp.Sub(st.Since)
+		if !st.Escalated && (r.Remaining < 0 || spell >= hours(a.Escalation.After, 24)) {
+			msg := fmt.Sprintf("Warning: Remaining electricity is %.2f", r.Remaining)
+			if r.Remaining < 0 {
+				msg += ", the balance is negative"
+			} else {
+				msg += fmt.Sprintf(", at or below %.2f for %.0fh", critical, spell.Hours())
+			}
+			if a.Label != "" {
+				msg += "\nRoom: " + a.Label
+			}
+			alert := Alert{Message: msg + "\nYou are receiving this as the secondary contact.", Rule: "escalation", Reading: r}
+			if err := a.Notify(alert); err != nil {
+				log.Printf("Failed to send escalation: %v", err)
+				return
+			}
+			st.Escalated = true
+		}
+	}
+	if st != before {
+		if err := a.State.Put(a.roomKey(escalationKey), st); err != nil {
+			log.Printf("Failed to save escalation state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventKind names something that happened during a run
+type EventKind string
+
+const (
+	EventReading         EventKind = "reading_received"
+	EventThreshold       EventKind = "threshold_crossed"
+	EventFetchFailed     EventKind = "fetch_failed"
+	EventDelivered       EventKind = "delivery_succeeded"
+	EventDeliveryFailed  EventKind = "delivery_failed"
+	EventAudited         EventKind = "delivery_audited" // logged instead of sent by an audit channel
+	EventAlertSuppressed EventKind = "alert_suppressed"
+	EventDataQuality     EventKind = "data_quality"
+)
+
+// Event is published on the bus; fields irrelevant to the kind stay zero
+type Event struct {
+	Kind     EventKind     `json:"kind"`
+	Time     time.Time     `json:"time"`
+	Alert    Alert         `json:"alert"`
+	Room     string        `json:"room,omitempty"` // label of the room the run was for
+	Channel  string        `json:"channel,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"` // delivery time
+	Err      error         `json:"-"`
+}
+
+// Bus delivers events synchronously to every subscriber of their kind
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[EventKind][]func(Event)
+	all  []func(Event)
+}
+
+// NewBus creates an empty event bus
+func NewBus() *Bus {
+	return &Bus{subs: make(map[EventKind][]func(Event))}
+}
+
+// Subscribe registers fn for the given kinds, or for every kind if none are given
+func (b *Bus) Subscribe(fn func(Event), kinds ...EventKind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(kinds) == 0 {
+		b.all = append(b.all, fn)
+		return
+	}
+	for _, k := range kinds {
+		b.subs[k] = append(b.subs[k], fn)
+	}
+}
+
+// Publish hands e to its subscribers; a nil bus drops the event
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := append(append([]func(Event){}, b.subs[e.Kind]...), b.all...)
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// Audit configures the append-only audit log of bus events. Audit
+// channels run through everything but log the rendered message here
+// instead of sending it, e.g. while onboarding a new channel.
+type Audit struct {
+	File     string   // JSON lines file, disabled when empty
+	Channels []string // audit channels
+	All      bool     // every channel is an audit channel
+}
+
+// Apply marks the audit channels
+func (A *Audit) Apply(channels []Channel) []Channel {
+	for i, ch := range channels {
+		if A.All || (len(A.Channels) > 0 && (Alert{Channels: A.Channels}).Targets(ch.Name())) {
+			channels[i].Audit = true
+		}
+	}
+	return channels
+}
+
+// auditEntry is one line of the audit log
+type auditEntry struct {
+	Event
+	Error string `json:"error,omitempty"`
+}
+
+// Attach subscribes the audit log to every event on the bus
+func (A *Audit) Attach(bus *Bus) {
+	if A.File == "" {
+		return
+	}
+	var mu sync.Mutex
+	bus.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := A.write(e); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write audit log: %v\n", err)
+		}
+	})
+}
+
+// write appends a single event to the audit file
+func (A *Audit) write(e Event) error {
+	f, err := os.OpenFile(A.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entry := auditEntry{Event: e}
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	}
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// Events reads the audited events of the given kinds since a time
+func (A *Audit) Events(since time.Time, kinds ...EventKind) ([]Event, error) {
+	if A.File == "" {
+		return nil, nil
+	}
+	f, err := os.Open(A.File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return events, fmt.Errorf("failed to parse audit log: %w", err)
+		}
+		if e.Time.Before(since) {
+			continue
+		}
+		for _, k := range kinds {
+			if e.Kind == k {
+				events = append(events, e)
+				break
+			}
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeScheme marks fetchers and notifiers that never touch the network
+const FakeScheme = "fake://"
+
+// IsFake reports whether an address selects a fake provider
+func IsFake(addr string) bool {
+	return strings.HasPrefix(addr, FakeScheme)
+}
+
+// FakeFetcher produces deterministic readings described by a fake:// URL:
+// fake://?used=80&total=100&step=1.5&fail=2&room=101 starts at 80 of 100
+// units, adds step to used on every call and fails the first fail calls.
+// meter=2025-01-10T03:00 reports that as the meter-read time.
+type FakeFetcher struct {
+	mu    sync.Mutex
+	used  float64
+	total float64
+	step  float64
+	fail  int
+	calls int
+	room  string
+	meter time.Time
+}
+
+// NewFakeFetcher parses a fake:// URL into a fetcher
+func NewFakeFetcher(addr string) (*FakeFetcher, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fake fetcher address: %w", err)
+	}
+	q := u.Query()
+	f := &FakeFetcher{used: 80, total: 100, room: q.Get("room")}
+	for key, dst := range map[string]*float64{"used": &f.used, "total": &f.total, "step": &f.step} {
+		if v := q.Get(key); v != "" {
+			if *dst, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid fake fetcher %s: %w", key, err)
+			}
+		}
+	}
+	if v := q.Get("meter"); v != "" {
+		if f.meter, err = ParseTime(v); err != nil {
+			return nil, fmt.Errorf("invalid fake fetcher meter: %w", err)
+		}
+	}
+	if v := q.Get("fail"); v != "" {
+		if f.fail, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid fake fetcher fail: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// GetMsg returns the next deterministic reading
+func (f *FakeFetcher) GetMsg() (Reading, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.fail {
+		return Reading{}, errors.New("fake fetcher failure")
+	}
+	used := f.used
+	f.used += f.step
+	reading := NewReading(used, f.total, f.room)
+	reading.MeterTime = f.meter
+	return reading, nil
+}
+
+// ConsoleNotifier prints messages instead of delivering them
+type ConsoleNotifier struct {
+	Channel string
+}
+
+// Name identifies the channel the console stands in for
+func (c *ConsoleNotifier) Name() string { return c.Channel }
+
+// Notify prints the message to stdout
+func (c *ConsoleNotifier) Notify(msg string) error {
+	fmt.Printf("[%s] %s\n", c.Channel, msg)
+	return nil
+}
+
+// SendAlbum prints a summary of the album instead of sending it
+func (c *ConsoleNotifier) SendAlbum(caption string, photos []Photo) error {
+	fmt.Printf("[%s] album of %d charts: %s\n", c.Channel, len(photos), caption)
+	return nil
+}
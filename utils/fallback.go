@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Delivery modes accepted in Delivery.Mode
+const (
+	BroadcastMode = "broadcast" // send to every channel at once, the default
+	FallbackMode  = "fallback"  // walk the chain until one channel succeeds
+)
+
+// Delivery selects how an alert is spread over the channels
+type Delivery struct {
+	Mode        string   `example:"broadcast"`
+	Chain       []string `example:"[Telegram, Email]"` // channel names in priority order, fallback mode only
+	StepTimeout int      `example:"10"`                // seconds to wait on a channel before moving on
+}
+
+// Validate rejects unknown modes and fallback without a chain
+func (d Delivery) Validate() error {
+	switch d.Mode {
+	case "", BroadcastMode:
+		return nil
+	case FallbackMode:
+		if len(d.Chain) == 0 {
+			return errors.New("fallback delivery needs a channel chain")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown delivery mode %q", d.Mode)
+}
+
+// fallback tries the jobs in chain order, one at a time, and returns
+// the jobs that were attempted; the last one is the first success
+func (a *App) fallback(jobs []delivery) []delivery {
+	timeout := time.Duration(a.Delivery.StepTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = a.notifyTimeout()
+	}
+
+	var attempted []delivery
+	for _, name := range a.Delivery.Chain {
+		for _, job := range jobs {
+			if !strings.EqualFold(job.Name(), name) {
+				continue
+			}
+			a.send(&job, timeout)
+			attempted = append(attempted, job)
+			if job.err == nil {
+				return attempted
+			}
+		}
+	}
+	return attempted
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feishu posts alerts through a Feishu (Lark) group custom bot
+type Feishu struct {
+	WebhookURL   string // https://open.feishu.cn/open-apis/bot/v2/hook/...
+	Secret       string // signing secret, when the bot has 签名校验 enabled
+	MsgType      string `example:"post"` // "post" (default, rich text with a title) or "text"
+	WarningsOnly bool
+}
+
+// Name identifies the Feishu channel
+func (F *Feishu) Name() string { return "Feishu" }
+
+// Notify implements Notifier by posting the message to the group bot
+func (F *Feishu) Notify(msg string) error {
+	body := map[string]interface{}{}
+	switch F.MsgType {
+	case "", "post":
+		// The first line becomes the title, every further line a paragraph
+		title, rest, _ := strings.Cut(msg, "\n")
+		paragraphs := [][]map[string]string{}
+		for _, line := range strings.Split(rest, "\n") {
+			if line != "" {
+				paragraphs = append(paragraphs, []map[string]string{{"tag": "text", "text": line}})
+			}
+		}
+		body["msg_type"] = "post"
+		body["content"] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"zh_cn": map[string]interface{}{"title": title, "content": paragraphs},
+			},
+		}
+	case "text":
+		body["msg_type"] = "text"
+		body["content"] = map[string]string{"text": msg}
+	default:
+		return fmt.Errorf("unknown Feishu message type %q", F.MsgType)
+	}
+	if F.Secret != "" {
+		timestamp, sign := F.sign(time.Now())
+		body["timestamp"], body["sign"] = timestamp, sign
+	}
+
+	var res struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(F.WebhookURL, nil, body, &res); err != nil {
+		return fmt.Errorf("failed to send Feishu message: %w", err)
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("Feishu bot failed: %d %s", res.Code, res.Msg)
+	}
+	return nil
+}
+
+// sign computes the timestamp and signature of signed webhooks: the
+// HMAC-SHA256 of an empty message keyed with timestamp and secret
+func (F *Feishu) sign(now time.Time) (timestamp, sign string) {
+	timestamp = strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+F.Secret))
+	return timestamp, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// Field is a derived value computed before the rules run, e.g.
+// {"Name": "pct_remaining", "Expr": "remaining / total * 100"}. Expr is
+// a Starlark expression over the rule variables, the fields defined
+// before it and the past week's history_remaining and history_used lists.
+type Field struct {
+	Name string
+	Expr string
+}
+
+// starlarkEnv converts rule variables into Starlark values
+func starlarkEnv(vars map[string]interface{}) starlark.StringDict {
+	env := starlark.StringDict{}
+	for k, v := range vars {
+		if sv, ok := toStarlark(v); ok {
+			env[k] = sv
+		}
+	}
+	return env
+}
+
+func toStarlark(v interface{}) (starlark.Value, bool) {
+	switch v := v.(type) {
+	case float64:
+		return starlark.Float(v), true
+	case int:
+		return starlark.MakeInt(v), true
+	case string:
+		return starlark.String(v), true
+	case bool:
+		return starlark.Bool(v), true
+	case []float64:
+		list := make([]starlark.Value, len(v))
+		for i, f := range v {
+			list[i] = starlark.Float(f)
+		}
+		return starlark.NewList(list), true
+	}
+	return nil, false
+}
+
+// fromStarlark converts a field result back for rules and templates
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s out of range", v)
+		}
+		return int(i), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bool:
+		return bool(v), nil
+	}
+	return nil, fmt.Errorf("unsupported result type %s", v.Type())
+}
+
+// computeFields evaluates the fields in order and adds them to vars. A
+// failing field is logged and left out, so it cannot block the alert.
+func (a *App) computeFields(r Reading, vars map[string]interface{}) {
+	if len(a.Fields) == 0 {
+		return
+	}
+	var remaining, used []float64
+	history, err := pastStore{Store: a.Store, until: r.Timestamp}.History(r.Timestamp.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+	}
+	for _, h := range history {
+		remaining = append(remaining, h.Remaining)
+		used = append(used, h.Used)
+	}
+	vars["history_remaining"], vars["history_used"] = remaining, used
+
+	env := starlarkEnv(vars)
+	for _, f := range a.Fields {
+		expr := strings.NewReplacer("&&", " and ", "||", " or ").Replace(f.Expr)
+		res, err := starlark.Eval(&starlark.Thread{Name: "field " + f.Name}, f.Name, expr, env)
+		if err == nil {
+			var v interface{}
+			if v, err = fromStarlark(res); err == nil {
+				vars[f.Name], env[f.Name] = v, res
+				continue
+			}
+		}
+		log.Printf("Failed to compute field %q: %v", f.Name, err)
+	}
+}
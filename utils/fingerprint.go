@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Fingerprint shapes the requests to the campus API so they can match
+// the official WeChat mini-program, should the backend start filtering
+// unknown clients
+type Fingerprint struct {
+	Preset         string   // "wechat" fills in the fields left empty
+	UserAgent      string   // overrides a User-Agent in RequestData.Headers
+	AcceptLanguage string   // e.g. zh-CN,zh;q=0.9
+	HeaderOrder    []string // header names in the order sent, others follow
+}
+
+// wechatFingerprint resembles the mini-program running in WeChat on Android
+var wechatFingerprint = Fingerprint{
+	UserAgent: "Mozilla/5.0 (Linux; Android 13; M2012K11AC Build/TKQ1.220829.002; wv) AppleWebKit/537.36 " +
+		"(KHTML, like Gecko) Version/4.0 Chrome/116.0.0.0 Mobile Safari/537.36 XWEB/1160065 MMWEBSDK/20231202 " +
+		"MMWEBID/2247 MicroMessenger/8.0.47.2560(0x28002F30) WeChat/arm64 Weixin NetType/WIFI Language/zh_CN " +
+		"ABI/arm64 miniProgram",
+	AcceptLanguage: "zh-CN,zh-SG;q=0.9,zh;q=0.8,en-US;q=0.7,en;q=0.6",
+	HeaderOrder: []string{"Host", "Connection", "Content-Length", "Accept", "Authorization", "User-Agent",
+		"Content-Type", "Origin", "X-Requested-With", "Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-Dest",
+		"Referer", "Accept-Encoding", "Accept-Language"},
+}
+
+// resolved returns the fingerprint with the preset applied
+func (f Fingerprint) resolved() (Fingerprint, error) {
+	switch strings.ToLower(f.Preset) {
+	case "":
+		return f, nil
+	case "wechat":
+		p := wechatFingerprint
+		if f.UserAgent != "" {
+			p.UserAgent = f.UserAgent
+		}
+		if f.AcceptLanguage != "" {
+			p.AcceptLanguage = f.AcceptLanguage
+		}
+		if len(f.HeaderOrder) > 0 {
+			p.HeaderOrder = f.HeaderOrder
+		}
+		return p, nil
+	}
+	return f, fmt.Errorf("unknown fingerprint preset %q", f.Preset)
+}
+
+// apply sets the fingerprint headers on req
+func (f Fingerprint) apply(req *http.Request) {
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	if f.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", f.AcceptLanguage)
+	}
+}
+
+// orderedTransport sends requests through net/http with the headers in
+// a fixed order, which net/http does not offer by itself: its
+// connections rewrite the header block written on them. Connections are
+// not reused, so each carries a single request. Proxies are dialed here
+// rather than by net/http, which would put its own TLS over the tunnel.
+func orderedTransport(order []string, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	d := &tunnelDialer{proxy: proxy, dialer: &net.Dialer{Timeout: 30 * time.Second}}
+	return &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			conn, err := d.dial(ctx, "http", addr)
+			if err != nil {
+				return nil, err
+			}
+			return &orderedConn{Conn: conn, order: order}, nil
+		},
+		DialTLSContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			conn, err := d.dial(ctx, "https", addr)
+			if err != nil {
+				return nil, err
+			}
+			cfg := tlsConfig.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return &orderedConn{Conn: tlsConn, order: order}, nil
+		},
+	}
+}
+
+// tunnelDialer connects to an address directly or through the proxy
+// chosen for it: SOCKS5, or an HTTP(S) proxy via CONNECT
+type tunnelDialer struct {
+	proxy  func(*http.Request) (*url.URL, error)
+	dialer *net.Dialer
+}
+
+// dial connects to addr, which requests of scheme are sent to
+func (d *tunnelDialer) dial(ctx context.Context, scheme, addr string) (net.Conn, error) {
+	var u *url.URL
+	if d.proxy != nil {
+		var err error
+		if u, err = d.proxy(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}}); err != nil {
+			return nil, err
+		}
+	}
+	if u == nil {
+		return d.dialer.DialContext(ctx, "tcp", addr)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(u, d.dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	case "http", "https":
+		return d.connect(ctx, u, addr)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+}
+
+// connect opens a CONNECT tunnel to addr through an HTTP(S) proxy
+func (d *tunnelDialer) connect(ctx context.Context, u *url.URL, addr string) (net.Conn, error) {
+	secure := strings.EqualFold(u.Scheme, "https")
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := d.dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	// Give up on the proxy along with the request
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	req := &http.Request{Method: "CONNECT", URL: &url.URL{Opaque: addr}, Host: addr, Header: http.Header{}}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused the tunnel: %s", resp.Status)
+	}
+	return conn, nil
+}
+
+// orderedConn reorders the header block of the request written on it
+// and passes everything else through
+type orderedConn struct {
+	net.Conn
+	order []string
+	head  []byte // start of the request until its header block is complete
+	sent  bool
+}
+
+func (c *orderedConn) Write(p []byte) (int, error) {
+	if c.sent {
+		return c.Conn.Write(p)
+	}
+	c.head = append(c.head, p...)
+	end := bytes.Index(c.head, []byte("\r\n\r\n"))
+	if end < 0 {
+		return len(p), nil
+	}
+	c.sent = true
+	out := append(orderHeaders(c.head[:end], c.order), "\r\n\r\n"...)
+	out = append(out, c.head[end+4:]...)
+	c.head = nil
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// orderHeaders puts the header lines after the request line in order;
+// headers not listed follow in the order net/http wrote them
+func orderHeaders(head []byte, order []string) []byte {
+	lines := strings.Split(string(head), "\r\n")
+	rest := lines[1:]
+	sorted := []string{lines[0]}
+	for _, name := range order {
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		var keep []string
+		for _, line := range rest {
+			if field, _, _ := strings.Cut(line, ":"); textproto.CanonicalMIMEHeaderKey(field) == key {
+				sorted = append(sorted, line)
+			} else {
+				keep = append(keep, line)
+			}
+		}
+		rest = keep
+	}
+	return []byte(strings.Join(append(sorted, rest...), "\r\n"))
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxForecastDays bounds how far ahead a depletion date is searched
+const maxForecastDays = 366
+
+// ForecastRunout projects when the remaining balance reaches zero if
+// ratePerDay is consumed on every occupied day of the calendar.
+// It reports false when the balance is not expected to run out.
+func ForecastRunout(start time.Time, remaining, ratePerDay float64, cal *Calendar) (time.Time, bool) {
+	if remaining <= 0 {
+		return start, true
+	}
+	if ratePerDay <= 0 {
+		return time.Time{}, false
+	}
+	day := start
+	for i := 0; i < maxForecastDays; i++ {
+		if !cal.Empty(day) {
+			if remaining <= ratePerDay {
+				return day.Add(time.Duration(remaining / ratePerDay * 24 * float64(time.Hour))), true
+			}
+			remaining -= ratePerDay
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// Projection answers whether the balance lasts until a target date
+type Projection struct {
+	Target  time.Time
+	Lasts   bool
+	Known   bool      // false when there is not enough history for a rate
+	Runout  time.Time // projected depletion, zero if it never runs out
+	Rate    float64   // units per occupied day
+	Reading Reading   // the reading the projection starts from
+}
+
+// String phrases the projection as an answer
+func (p Projection) String() string { return p.Format(Locale{}) }
+
+// Format phrases the projection with the dates written for l
+func (p Projection) Format(l Locale) string {
+	target := l.Date(p.Target)
+	switch {
+	case !p.Known:
+		return fmt.Sprintf("Not enough history yet to tell whether %.2f lasts until %s.", p.Reading.Remaining, target)
+	case p.Lasts:
+		return fmt.Sprintf("Yes, %.2f at %.2f per day should last until %s.", p.Reading.Remaining, p.Rate, target)
+	default:
+		return fmt.Sprintf("No, %.2f at %.2f per day runs out around %s, before %s.",
+			p.Reading.Remaining, p.Rate, l.Date(p.Runout), target)
+	}
+}
+
+// LastsUntil projects the latest stored reading to the end of target's
+// day using the consumption rate of the past week
+func (a *App) LastsUntil(target time.Time) (Projection, error) {
+	p := Projection{Target: target}
+	history, err := a.Store.History(a.Clock.Now().AddDate(0, 0, -7))
+	if err != nil {
+		return p, err
+	}
+	if len(history) == 0 {
+		return p, errors.New("no reading recorded yet")
+	}
+	p.Reading = history[len(history)-1]
+	p.Rate, p.Known = ConsumptionRate(history, p.Reading)
+	if !p.Known {
+		return p, nil
+	}
+	end := time.Date(target.Year(), target.Month(), target.Day(), 0, 0, 0, 0, target.Location()).AddDate(0, 0, 1)
+	runout, ok := ForecastRunout(p.Reading.Timestamp, p.Reading.Remaining, p.Rate, &a.Calendar)
+	p.Lasts = !ok || !runout.Before(end)
+	if ok {
+		p.Runout = runout
+	}
+	return p, nil
+}
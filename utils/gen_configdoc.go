@@ -0,0 +1,131 @@
+//go:build ignore
+
+// gen_configdoc writes configdoc_gen.go, the field and type comments of
+// the configuration structs, so that `config example` can comment the
+// fields without the sources. Run it with `go generate ./utils`.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != "configdoc_gen.go" && name != "gen_configdoc.go"
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Collect the struct types with their doc comments
+	type structType struct {
+		doc *ast.CommentGroup
+		st  *ast.StructType
+	}
+	types := map[string]structType{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						// Named slices such as DataFeeds lead to their element
+						if arr, ok := ts.Type.(*ast.ArrayType); ok {
+							types[ts.Name.Name] = structType{st: &ast.StructType{Fields: &ast.FieldList{
+								List: []*ast.Field{{Type: arr.Elt}},
+							}}}
+						}
+						continue
+					}
+					doc := ts.Doc
+					if doc == nil && len(gen.Specs) == 1 {
+						doc = gen.Doc
+					}
+					types[ts.Name.Name] = structType{doc, st}
+				}
+			}
+		}
+	}
+
+	// Document the types reachable from Config
+	docs := map[string]string{}
+	seen := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		t, ok := types[name]
+		if !ok || seen[name] {
+			return
+		}
+		seen[name] = true
+		// Only proper doc comments, which start with the type name
+		if text := firstSentence(t.doc.Text()); strings.HasPrefix(text, name+" ") {
+			docs[name] = text
+		}
+		for _, field := range t.st.Fields.List {
+			text := strings.TrimSpace(field.Comment.Text())
+			if text == "" {
+				text = strings.TrimSpace(field.Doc.Text())
+			}
+			for _, n := range field.Names {
+				if text != "" {
+					docs[name+"."+n.Name] = strings.Join(strings.Fields(text), " ")
+				}
+			}
+			ast.Inspect(field.Type, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					visit(id.Name)
+				}
+				return true
+			})
+		}
+	}
+	visit("Config")
+
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen_configdoc.go; DO NOT EDIT.\n\npackage utils\n\n")
+	b.WriteString("// configDocs maps \"Type.Field\" to the field comment and \"Type\" to the\n")
+	b.WriteString("// first sentence of the type's doc comment\n")
+	b.WriteString("var configDocs = map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\t%q: %q,\n", k, docs[k])
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("configdoc_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// firstSentence shortens a doc comment to its first sentence, joined
+// onto one line
+func firstSentence(doc string) string {
+	doc = strings.Join(strings.Fields(doc), " ")
+	if i := strings.Index(doc, ". "); i >= 0 {
+		doc = doc[:i+1]
+	}
+	return doc
+}
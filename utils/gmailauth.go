@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/gmail/v1"
+)
+
+// oauthConfig reads the Gmail API client from CredentialsFile
+func (E *Email) oauthConfig() (*oauth2.Config, error) {
+	b, err := os.ReadFile(E.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+	cfg, err := google.ConfigFromJSON(b, gmail.GmailSendScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file: %w", err)
+	}
+	return cfg, nil
+}
+
+// Authorize runs the OAuth consent flow with a redirect to a local
+// server on listen and saves the token to TokenFile. The consent link
+// is written to out and may be opened in any browser that reaches
+// listen, e.g. through `ssh -L` or a published Docker port.
+func (E *Email) Authorize(ctx context.Context, listen string, out io.Writer) error {
+	cfg, err := E.oauthConfig()
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the OAuth redirect: %w", err)
+	}
+	defer ln.Close()
+	// Google accepts any port on the loopback address for desktop clients
+	port := ln.Addr().(*net.TCPAddr).Port
+	cfg.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d/", port)
+
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("state") != hex.EncodeToString(state):
+			http.Error(w, "Unexpected state, open the link printed by auth again.", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			http.Error(w, "Authorization failed: "+q.Get("error"), http.StatusBadRequest)
+			errs <- fmt.Errorf("authorization was declined: %s", q.Get("error"))
+		case q.Get("code") == "":
+			http.Error(w, "Missing authorization code.", http.StatusBadRequest)
+			return
+		default:
+			fmt.Fprintln(w, "Authorized, you can close this page.")
+			codes <- q.Get("code")
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	authURL := cfg.AuthCodeURL(hex.EncodeToString(state), oauth2.AccessTypeOffline,
+		oauth2.ApprovalForce, oauth2.S256ChallengeOption(verifier))
+	fmt.Fprintf(out, "Open the following link in your browser, it redirects to %s:\n%s\n", cfg.RedirectURL, authURL)
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve token from web: %w", err)
+	}
+	fmt.Fprintf(out, "Saving credential file to: %s\n", E.TokenFile)
+	return writeToken(E.TokenFile, token)
+}
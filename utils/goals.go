@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Goals are targets set by the user, reported on in the consumption
+// report and alerted on the first time they are missed
+type Goals struct {
+	MinBalance    float64 // keep at least this many units, 0 disables
+	MonthlyBudget float64 // spend at most this much per month, needs Pricing
+}
+
+const goalsKey = "goals_breached"
+
+// goalState remembers which goals were already reported as missed
+type goalState struct {
+	Balance bool   // below MinBalance since the last alert
+	Budget  string // month (2006-01) whose budget was reported as exceeded
+}
+
+// budgetEnabled reports whether the budget goal can be judged
+func (a *App) budgetEnabled() bool {
+	return a.Goals.MonthlyBudget > 0 && a.Pricing.Enabled()
+}
+
+// GoalProgress describes progress towards the goals for the report;
+// low is the lowest balance over the reported period
+func (a *App) GoalProgress(low float64) string {
+	var lines []string
+	if min := a.Goals.MinBalance; min > 0 {
+		status := "met"
+		if low < min {
+			status = "missed"
+		}
+		lines = append(lines, fmt.Sprintf("Goal: keep at least %.2f, lowest was %.2f (%s).", min, low, status))
+	}
+	if a.budgetEnabled() {
+		soFar, projected, err := a.MonthCost()
+		if err != nil {
+			log.Printf("Failed to estimate cost: %v", err)
+		} else {
+			status := "on track"
+			if soFar > a.Goals.MonthlyBudget {
+				status = "exceeded"
+			} else if projected > a.Goals.MonthlyBudget {
+				status = "expected to be exceeded"
+			}
+			lines = append(lines, fmt.Sprintf("Budget: %s of %s spent this month (%s).",
+				a.Pricing.Format(soFar), a.Pricing.Format(a.Goals.MonthlyBudget), status))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkGoals sends a warning the first time a goal is missed: once per
+// dip below the balance goal and once per month over the budget
+func (a *App) checkGoals(r Reading) {
+	if (a.Goals.MinBalance <= 0 && !a.budgetEnabled()) || a.State == nil {
+		return
+	}
+	var st goalState
+	if _, err := a.State.Get(a.roomKey(goalsKey), &st); err != nil {
+		log.Printf("Failed to read goal state: %v", err)
+	}
+	before := st
+
+	var missed []string
+	if min := a.Goals.MinBalance; min > 0 {
+		below := r.Remaining < min
+		if below && !st.Balance {
+			missed = append(missed, fmt.Sprintf("balance %.2f is below your goal of %.2f", r.Remaining, min))
+		}
+		st.Balance = below
+	}
+	if a.budgetEnabled() {
+		month := r.Timestamp.Format("2006-01")
+		if soFar, _, err := a.MonthCost(); err != nil {
+			log.Printf("Failed to estimate cost: %v", err)
+		} else if soFar > a.Goals.MonthlyBudget && st.Budget != month {
+			missed = append(missed, fmt.Sprintf("%s spent this month, over your budget of %s",
+				a.Pricing.Format(soFar), a.Pricing.Format(a.Goals.MonthlyBudget)))
+			st.Budget = month
+		}
+	}
+
+	if len(missed) > 0 {
+		alert := Alert{Message: "Warning: Goal missed: " + strings.Join(missed, "; "), Rule: "goal", Reading: r}
+		if err := a.Notify(alert); err != nil {
+			log.Printf("Failed to send goal warning: %v", err)
+			return
+		}
+	}
+	if st != before {
+		if err := a.State.Put(a.roomKey(goalsKey), st); err != nil {
+			log.Printf("Failed to save goal state: %v", err)
+		}
+	}
+}
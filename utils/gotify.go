@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Gotify pushes alerts to a self-hosted Gotify server
+type Gotify struct {
+	URL          string         // server address, e.g. https://push.example.com
+	Token        string         // application token
+	Priorities   map[string]int `example:"{normal: 1, info: 3, warning: 5, critical: 8}"` // priority by level: normal, info, warning, critical
+	WarningsOnly bool
+}
+
+// gotifyPriorities map message levels to Gotify priorities; 8 and above
+// ring through Do Not Disturb on Android
+var gotifyPriorities = map[string]int{
+	LevelNormal:   1,
+	LevelInfo:     3,
+	LevelWarning:  5,
+	LevelCritical: 8,
+}
+
+// Name identifies the Gotify channel
+func (G *Gotify) Name() string { return "Gotify" }
+
+// priority returns the configured or default priority for a level
+func (G *Gotify) priority(level string) int {
+	if p, ok := G.Priorities[level]; ok {
+		return p
+	}
+	return gotifyPriorities[level]
+}
+
+// Notify implements Notifier for messages without an alert
+func (G *Gotify) Notify(msg string) error { return G.send(msg, MessageLevel(msg)) }
+
+// NotifyAlert implements AlertNotifier, prioritizing by the alert's level
+func (G *Gotify) NotifyAlert(alert Alert, msg string) error { return G.send(msg, alert.Severity()) }
+
+// send creates a Gotify message with the priority of level
+func (G *Gotify) send(msg, level string) error {
+	title, body, _ := strings.Cut(msg, "\n")
+	if body == "" {
+		body = title
+	}
+	endpoint := strings.TrimRight(G.URL, "/") + "/message?token=" + url.QueryEscape(G.Token)
+	err := postJSON(endpoint, nil, map[string]interface{}{
+		"title":    title,
+		"message":  body,
+		"priority": G.priority(level),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to send Gotify message: %w", err)
+	}
+	return nil
+}
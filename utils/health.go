@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health configures the daemon's health endpoint, e.g. for a Docker
+// HEALTHCHECK or a Kubernetes liveness probe
+type Health struct {
+	Listen string // address serving /healthz, e.g. :8081; disabled when empty
+	Grace  int    `example:"10"` // minutes a check may be overdue before the daemon counts as stuck, defaults to 10
+}
+
+// grace is how long a check may be overdue
+func (h Health) grace() time.Duration {
+	if h.Grace <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(h.Grace) * time.Minute
+}
+
+// URL is where the health endpoint is reached from the same host; an
+// address without a host is asked on the loopback interface
+func (h Health) URL() string {
+	host, port, err := net.SplitHostPort(h.Listen)
+	if err != nil {
+		return "http://" + h.Listen + "/healthz"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/healthz"
+}
+
+// Heartbeat follows the daemon loop. The daemon is healthy while its
+// next check is not overdue by more than the grace period; failed
+// fetches are reported but left to the alerts, since a restart does not
+// fix the campus API.
+type Heartbeat struct {
+	grace time.Duration
+
+	mu          sync.Mutex
+	started     time.Time
+	next        time.Time
+	lastCheck   time.Time
+	lastSuccess time.Time
+	lastErr     string
+}
+
+// NewHeartbeat starts following a daemon
+func NewHeartbeat(h Health) *Heartbeat {
+	return &Heartbeat{grace: h.grace(), started: time.Now()}
+}
+
+// Scheduled records that the daemon wakes up after wait. A nil
+// Heartbeat records nothing.
+func (h *Heartbeat) Scheduled(wait time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next = time.Now().Add(wait)
+}
+
+// Checked records the outcome of a check
+func (h *Heartbeat) Checked(err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCheck = time.Now()
+	h.lastErr = ""
+	if err != nil {
+		h.lastErr = err.Error()
+		return
+	}
+	h.lastSuccess = h.lastCheck
+}
+
+// ServeHTTP answers 200 while healthy and 503 once a check is overdue,
+// with the daemon's progress as JSON
+func (h *Heartbeat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy := h.next.IsZero() || time.Now().Before(h.next.Add(h.grace))
+	res := map[string]interface{}{
+		"status":  "ok",
+		"started": h.started,
+	}
+	if !healthy {
+		res["status"] = "stuck"
+	}
+	for key, t := range map[string]time.Time{"next_check": h.next, "last_check": h.lastCheck, "last_success": h.lastSuccess} {
+		if !t.IsZero() {
+			res[key] = t
+		}
+	}
+	if h.lastErr != "" {
+		res["last_error"] = h.lastErr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
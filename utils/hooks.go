@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Hooks transforms or suppresses messages at defined points of a run.
+// A false keep result suppresses the message.
+type Hooks interface {
+	AfterFetch(alert Alert) (out string, keep bool, err error)
+	BeforeNotify(channel string, alert Alert) (out string, keep bool, err error)
+}
+
+// HookConfig points to a user-provided Starlark script
+type HookConfig struct {
+	Script string // path to a .star file defining after_fetch and/or before_notify
+}
+
+// Script runs hook functions defined in a Starlark file.
+// Each hook receives an event dict and returns None to suppress the
+// message, a string to replace it, or a dict whose "message" key replaces
+// it and whose remaining keys are appended as computed fields.
+type Script struct {
+	globals starlark.StringDict
+}
+
+// LoadScript executes the hook script once and keeps its functions
+func (H *HookConfig) LoadScript() (*Script, error) {
+	thread := &starlark.Thread{Name: "hooks"}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, H.Script, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hook script: %w", err)
+	}
+	return &Script{globals: globals}, nil
+}
+
+// AfterFetch runs the after_fetch hook, if defined
+func (s *Script) AfterFetch(alert Alert) (string, bool, error) {
+	return s.call("after_fetch", "", alert)
+}
+
+// BeforeNotify runs the before_notify hook, if defined
+func (s *Script) BeforeNotify(channel string, alert Alert) (string, bool, error) {
+	return s.call("before_notify", channel, alert)
+}
+
+// call invokes the named hook with an event dict describing the alert
+func (s *Script) call(name, channel string, alert Alert) (string, bool, error) {
+	msg := alert.Message
+	fn, ok := s.globals[name].(starlark.Callable)
+	if !ok {
+		return msg, true, nil
+	}
+
+	r := alert.Reading
+	event := starlark.NewDict(8)
+	event.SetKey(starlark.String("message"), starlark.String(msg))
+	event.SetKey(starlark.String("warning"), starlark.Bool(alert.IsWarning()))
+	event.SetKey(starlark.String("rule"), starlark.String(alert.Rule))
+	event.SetKey(starlark.String("used"), starlark.Float(r.Used))
+	event.SetKey(starlark.String("total"), starlark.Float(r.Total))
+	event.SetKey(starlark.String("remaining"), starlark.Float(r.Remaining))
+	event.SetKey(starlark.String("room"), starlark.String(r.Room))
+	if channel != "" {
+		event.SetKey(starlark.String("channel"), starlark.String(channel))
+	}
+
+	thread := &starlark.Thread{Name: name}
+	res, err := starlark.Call(thread, fn, starlark.Tuple{event}, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("hook %s failed: %w", name, err)
+	}
+
+	switch v := res.(type) {
+	case starlark.NoneType:
+		return "", false, nil
+	case starlark.String:
+		return string(v), true, nil
+	case *starlark.Dict:
+		return dictMessage(v, msg), true, nil
+	default:
+		return "", false, fmt.Errorf("hook %s returned unsupported type %s", name, res.Type())
+	}
+}
+
+// dictMessage renders a hook's dict result as message plus computed fields
+func dictMessage(d *starlark.Dict, fallback string) string {
+	msg := fallback
+	var extra []string
+	for _, item := range d.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			continue
+		}
+		value, ok := starlark.AsString(item[1])
+		if !ok {
+			value = item[1].String()
+		}
+		if key == "message" {
+			msg = value
+			continue
+		}
+		extra = append(extra, fmt.Sprintf("%s: %s", key, value))
+	}
+	sort.Strings(extra)
+	if len(extra) == 0 {
+		return msg
+	}
+	return msg + "\n" + strings.Join(extra, "\n")
+}
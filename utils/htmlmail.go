@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// defaultEmailTemplate lays out an alert with a banner colored by level
+const defaultEmailTemplate = `<!DOCTYPE html>
+<html><body style="margin:0;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#222">
+<div style="background:{{.Color}};color:#fff;padding:16px 20px;font-size:18px;font-weight:600">{{.Title}}</div>
+<div style="padding:16px 20px">
+{{if .HasReading}}<table style="border-collapse:collapse;margin-bottom:16px">
+<tr><td style="padding:4px 16px 4px 0;color:#666">Remaining</td><td style="font-size:24px;font-weight:600">{{printf "%.2f" .Remaining}} kWh</td></tr>
+{{if ge .SinceLast 0.0}}<tr><td style="padding:4px 16px 4px 0;color:#666">Used since last check</td><td>{{printf "%.2f" .SinceLast}} kWh</td></tr>{{end}}
+<tr><td style="padding:4px 16px 4px 0;color:#666">Used / total</td><td>{{printf "%.2f" .Used}} / {{printf "%.2f" .Total}}</td></tr>
+{{if .Room}}<tr><td style="padding:4px 16px 4px 0;color:#666">Room</td><td>{{.Room}}</td></tr>{{end}}
+<tr><td style="padding:4px 16px 4px 0;color:#666">Checked</td><td>{{.Time}}</td></tr>
+</table>{{end}}
+<div style="white-space:pre-wrap">{{.Body}}</div>
+</div></body></html>
+`
+
+// levelColors are the banner colors per message level
+var levelColors = map[string]string{
+	LevelCritical: "#c62828",
+	LevelWarning:  "#ef6c00",
+	LevelInfo:     "#1565c0",
+	LevelNormal:   "#2e7d32",
+}
+
+// emailView is the data given to the HTML email template
+type emailView struct {
+	Title, Body, Level, Color string
+	HasReading                bool
+	Remaining, Used, Total    float64
+	SinceLast                 float64 // -1 when unknown
+	Room, Time, AlertID       string
+	Vars                      map[string]interface{} // everything the rules saw
+}
+
+// newEmailView prepares the template data for a message and, when
+// known, the alert behind it
+func newEmailView(msg string, alert *Alert) emailView {
+	title, body, _ := strings.Cut(msg, "\n")
+	level := MessageLevel(msg)
+	if alert != nil {
+		level = alert.Severity()
+	}
+	v := emailView{Title: title, Body: body, Level: level, Color: levelColors[level], SinceLast: -1}
+	if alert == nil || alert.Reading.Timestamp.IsZero() {
+		return v
+	}
+	r := alert.Reading
+	v.HasReading = true
+	v.Remaining, v.Used, v.Total, v.Room = r.Remaining, r.Used, r.Total, r.Room
+	v.Time, v.AlertID, v.Vars = r.Timestamp.Format("2006-01-02 15:04"), alert.ID, alert.Vars
+	// Evaluate formats the timestamp by Locale
+	if ts, ok := alert.Vars["timestamp"].(string); ok {
+		v.Time = ts
+	}
+	if since, ok := alert.Vars["since_last"].(float64); ok {
+		v.SinceLast = since
+	}
+	return v
+}
+
+// renderHTML renders the HTML body, from Email.HTMLTemplate if given
+func (E *Email) renderHTML(v emailView) (string, error) {
+	text := defaultEmailTemplate
+	if E.HTMLTemplate != "" {
+		b, err := os.ReadFile(E.HTMLTemplate)
+		if err != nil {
+			return "", fmt.Errorf("failed to read email template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("email").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid email template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("failed to render email: %w", err)
+	}
+	return b.String(), nil
+}
+
+// subject renders the subject line from Email.Subject
+func (E *Email) subject(v emailView) (string, error) {
+	if E.Subject == "" {
+		return "Electricity Alert", nil
+	}
+	tmpl, err := texttemplate.New("subject").Parse(E.Subject)
+	if err != nil {
+		return "", fmt.Errorf("invalid email subject: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("failed to render email subject: %w", err)
+	}
+	// Header values must stay on one line
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// mimeMessage builds a UTF-8 message, multipart/alternative when an HTML
+// body is given and multipart/mixed around that when there are files.
+// Parts are base64 encoded so that Chinese room names survive any
+// server. An empty from leaves the sender to the server.
+func mimeMessage(from string, to, cc []string, subject, text, html string, files ...Attachment) []byte {
+	var b bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\r\n", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\nMIME-Version: 1.0\r\n", time.Now().Format(time.RFC1123Z))
+	if len(files) == 0 {
+		writeBody(&b, text, html)
+		return b.Bytes()
+	}
+	marker := mimeBoundary("mix")
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", marker)
+	fmt.Fprintf(&b, "--%s\r\n", marker)
+	writeBody(&b, text, html)
+	for _, f := range files {
+		fmt.Fprintf(&b, "--%s\r\n", marker)
+		fmt.Fprintf(&b, "Content-Type: %s\r\nContent-Disposition: attachment; filename=%q\r\nContent-Transfer-Encoding: base64\r\n\r\n",
+			f.ContentType, mime.QEncoding.Encode("utf-8", f.Name))
+		writeBase64(&b, f.Data)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", marker)
+	return b.Bytes()
+}
+
+// writeBody writes the text part, or both parts as multipart/alternative
+func writeBody(b *bytes.Buffer, text, html string) {
+	if html == "" {
+		writePart(b, "text/plain", text)
+		return
+	}
+	marker := mimeBoundary("alt")
+	fmt.Fprintf(b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", marker)
+	fmt.Fprintf(b, "--%s\r\n", marker)
+	writePart(b, "text/plain", text)
+	fmt.Fprintf(b, "--%s\r\n", marker)
+	writePart(b, "text/html", html)
+	fmt.Fprintf(b, "--%s--\r\n", marker)
+}
+
+// mimeBoundary returns a random multipart boundary
+func mimeBoundary(prefix string) string {
+	boundary := make([]byte, 12)
+	rand.Read(boundary)
+	return prefix + "-" + hex.EncodeToString(boundary)
+}
+
+// writePart writes the headers and base64 body of one MIME part
+func writePart(b *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(b, "Content-Type: %s; charset=UTF-8\r\nContent-Transfer-Encoding: base64\r\n\r\n", contentType)
+	writeBase64(b, []byte(body))
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
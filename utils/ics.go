@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// icsDays maps weekdays to iCalendar BYDAY codes
+var icsDays = map[time.Weekday]string{
+	time.Sunday: "SU", time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE",
+	time.Thursday: "TH", time.Friday: "FR", time.Saturday: "SA",
+}
+
+// ICS renders an iCalendar feed with the forecast run-out date and the
+// weekly report, for subscribing from a calendar app
+func (a *App) ICS(room string) (string, error) {
+	now := a.Clock.Now()
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\r\n", args...)
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//CUHKSZ-Electricity//EN")
+	line("X-WR-CALNAME:Electricity %s", room)
+
+	p, err := a.LastsUntil(now.AddDate(0, 0, maxForecastDays))
+	if err != nil {
+		return "", err
+	}
+	if p.Known && !p.Runout.IsZero() {
+		day := p.Runout
+		line("BEGIN:VEVENT")
+		line("UID:runout-%s@cuhksz-electricity", room)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART;VALUE=DATE:%s", day.Format("20060102"))
+		line("DTEND;VALUE=DATE:%s", day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:Electricity runs out ~%s", day.Format("Jan 2"))
+		line("DESCRIPTION:%.2f left at %.2f per day", p.Reading.Remaining, p.Rate)
+		line("END:VEVENT")
+	}
+
+	if a.Schedule.ReportWeekday != "" {
+		start, day, err := a.Schedule.NextReport(now)
+		if err != nil {
+			return "", err
+		}
+		line("BEGIN:VEVENT")
+		line("UID:report-%s@cuhksz-electricity", room)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART:%s", start.Format("20060102T150405"))
+		line("DURATION:PT15M")
+		line("RRULE:FREQ=WEEKLY;BYDAY=%s", icsDays[day])
+		line("SUMMARY:Electricity report")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.String(), nil
+}
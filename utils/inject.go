@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Injection deliberately fails fetches and slows down notifications, to
+// check that retries, fallbacks and escalation behave as configured
+type Injection struct {
+	FetchError    float64       // probability in [0, 1] that a fetch attempt fails
+	NotifyLatency time.Duration // added before every notification
+}
+
+// Environment variables read by InjectionFromEnv
+const (
+	injectFetchErrorEnv    = "ELECTRICITY_INJECT_FETCH_ERROR"
+	injectNotifyLatencyEnv = "ELECTRICITY_INJECT_NOTIFY_LATENCY"
+)
+
+// ErrInjected is returned by fetch attempts failed on purpose
+var ErrInjected = errors.New("injected fetch error")
+
+// InjectionFromEnv reads $ELECTRICITY_INJECT_FETCH_ERROR (e.g. 0.3) and
+// $ELECTRICITY_INJECT_NOTIFY_LATENCY (e.g. 5s)
+func InjectionFromEnv() (Injection, error) {
+	var i Injection
+	var err error
+	if v := os.Getenv(injectFetchErrorEnv); v != "" {
+		if i.FetchError, err = strconv.ParseFloat(v, 64); err != nil {
+			return i, fmt.Errorf("invalid %s: %w", injectFetchErrorEnv, err)
+		}
+	}
+	if v := os.Getenv(injectNotifyLatencyEnv); v != "" {
+		if i.NotifyLatency, err = time.ParseDuration(v); err != nil {
+			return i, fmt.Errorf("invalid %s: %w", injectNotifyLatencyEnv, err)
+		}
+	}
+	return i, i.Validate()
+}
+
+// Validate rejects probabilities outside [0, 1] and negative latencies
+func (i Injection) Validate() error {
+	if i.FetchError < 0 || i.FetchError > 1 {
+		return fmt.Errorf("injected fetch error rate %g is not between 0 and 1", i.FetchError)
+	}
+	if i.NotifyLatency < 0 {
+		return fmt.Errorf("injected notify latency %s is negative", i.NotifyLatency)
+	}
+	return nil
+}
+
+// Enabled reports whether anything is injected
+func (i Injection) Enabled() bool {
+	return i.FetchError > 0 || i.NotifyLatency > 0
+}
+
+// fetch calls get unless the attempt is failed on purpose
+func (i Injection) fetch(get func() (Reading, error)) (Reading, error) {
+	if i.FetchError > 0 && rand.Float64() < i.FetchError {
+		return Reading{}, ErrInjected
+	}
+	return get()
+}
+
+// delay waits out the injected notification latency
+func (i Injection) delay() {
+	if i.NotifyLatency > 0 {
+		time.Sleep(i.NotifyLatency)
+	}
+}
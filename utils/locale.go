@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locale formats the times and dates shown in messages, emails and the
+// dashboard. Rule variables other than timestamp keep ISO dates so that
+// rules compare them reliably.
+type Locale struct {
+	Language string `example:"en"` // "en" (default, 2025-01-10 21:30) or "zh" (2025年1月10日 21:30)
+	Hour12   bool   // 9:30 PM (下午9:30) instead of 21:30
+}
+
+// zhWeekdays are the short Chinese weekday names, Sunday first
+var zhWeekdays = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// Validate checks the language
+func (l Locale) Validate() error {
+	if !l.chinese() && l.Language != "" && !strings.EqualFold(l.Language, "en") {
+		return fmt.Errorf("unknown Locale.Language %q, use en or zh", l.Language)
+	}
+	return nil
+}
+
+// chinese reports whether dates are written the Chinese way
+func (l Locale) chinese() bool {
+	return strings.EqualFold(l.Language, "zh") || strings.HasPrefix(strings.ToLower(l.Language), "zh-")
+}
+
+// Clock formats the time of day
+func (l Locale) Clock(t time.Time) string {
+	switch {
+	case !l.Hour12:
+		return t.Format("15:04")
+	case !l.chinese():
+		return t.Format("3:04 PM")
+	case t.Hour() < 12:
+		return "上午" + t.Format("3:04")
+	}
+	return "下午" + t.Format("3:04")
+}
+
+// Date formats a calendar date
+func (l Locale) Date(t time.Time) string {
+	if l.chinese() {
+		return t.Format("2006年1月2日")
+	}
+	return t.Format("2006-01-02")
+}
+
+// DateTime formats a date with the time of day
+func (l Locale) DateTime(t time.Time) string {
+	return l.Date(t) + " " + l.Clock(t)
+}
+
+// Day formats a recent day with its weekday, e.g. Mon 01-02
+func (l Locale) Day(t time.Time) string {
+	if l.chinese() {
+		return t.Format("1月2日 ") + zhWeekdays[t.Weekday()]
+	}
+	return t.Format("Mon 01-02")
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LockConfig guards against overlapping runs
+type LockConfig struct {
+	File     string // lock file, defaults to the config path with .lock appended
+	Disabled bool
+}
+
+// Lock is a held lock file
+type Lock struct {
+	f *os.File
+}
+
+// ErrLocked reports that another instance holds the lock
+var ErrLocked = errors.New("another instance is running")
+
+// errLockHeld is returned by lockFile when another process holds the lock
+var errLockHeld = errors.New("file is locked by another process")
+
+// Acquire takes an exclusive lock on the lock file, which stays in place
+// between runs. The system drops the lock when its process exits, so a
+// crashed run leaves nothing stale behind. The zero Lock is returned
+// when locking is disabled.
+func (L *LockConfig) Acquire(configPath string) (*Lock, error) {
+	if L.Disabled {
+		return &Lock{}, nil
+	}
+	path := L.File
+	if path == "" {
+		path = configPath + ".lock"
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(f, false); err != nil {
+		f.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("%w (lock file %s)", ErrLocked, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	// The pid is only informational, the lock is what counts
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return &Lock{f: f}, nil
+}
+
+// Release unlocks the lock file, leaving it for the next run
+func (l *Lock) Release() {
+	if l.f != nil {
+		unlockFile(l.f)
+		l.f.Close()
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Login lets people sign in to the web dashboard with the Telegram login
+// widget instead of a separate password; the bot's domain must be set
+// with @BotFather /setdomain
+type Login struct {
+	BotUsername  string   // bot the widget belongs to, login is disabled when empty
+	AllowedIDs   []string // Telegram user IDs let in besides Telegram.UserID
+	Scopes       []string `example:"[read]"` // granted to signed-in users, defaults to read
+	SessionHours int      `example:"168"`    // session lifetime, defaults to 168 (a week)
+}
+
+// Enabled reports whether the login widget is configured
+func (L *Login) Enabled() bool { return L.BotUsername != "" }
+
+// Allows reports whether a signed-in user may act within scope
+func (L *Login) Allows(scope string) bool {
+	scopes := L.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeRead}
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is how long a sign-in lasts
+func (L *Login) Session() time.Duration {
+	return hours(L.SessionHours, 168)
+}
+
+// VerifyTelegramLogin checks the data the login widget passed back,
+// signed with the SHA-256 of the bot token, and returns the user ID.
+// Logins older than a day are rejected to limit replays.
+func VerifyTelegramLogin(botToken string, values url.Values, now time.Time) (string, error) {
+	var lines []string
+	for key := range values {
+		if key != "hash" {
+			lines = append(lines, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(lines)
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	want := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(values.Get("hash"))) {
+		return "", errors.New("invalid Telegram login signature")
+	}
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid Telegram login date: %w", err)
+	}
+	if now.Sub(time.Unix(authDate, 0)) > 24*time.Hour {
+		return "", errors.New("Telegram login has expired")
+	}
+	return values.Get("id"), nil
+}
+
+// sessionMAC signs a session value with a key derived from the bot token
+func sessionMAC(botToken, value string) string {
+	mac := hmac.New(sha256.New, []byte("session:"+botToken))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignSession returns a cookie value naming the user until expiry
+func SignSession(botToken, id string, expiry time.Time) string {
+	value := id + "." + strconv.FormatInt(expiry.Unix(), 10)
+	return value + "." + sessionMAC(botToken, value)
+}
+
+// VerifySession returns the user ID of a valid, unexpired session cookie
+func VerifySession(botToken, cookie string, now time.Time) (string, bool) {
+	i := strings.LastIndex(cookie, ".")
+	if i < 0 || botToken == "" {
+		return "", false
+	}
+	value, sig := cookie[:i], cookie[i+1:]
+	if !hmac.Equal([]byte(sessionMAC(botToken, value)), []byte(sig)) {
+		return "", false
+	}
+	id, exp, ok := strings.Cut(value, ".")
+	expiry, err := strconv.ParseInt(exp, 10, 64)
+	if !ok || err != nil || now.Unix() > expiry {
+		return "", false
+	}
+	return id, true
+}
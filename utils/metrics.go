@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Metrics configures the Prometheus endpoint of the daemon
+type Metrics struct {
+	Listen string // address serving /metrics, e.g. :9100; disabled when empty
+}
+
+// Exporter keeps the latest readings and failure counts from the event
+// buses of every check and serves them in the Prometheus text format
+type Exporter struct {
+	mu               sync.Mutex
+	readings         map[string]Reading // by room
+	fetchFailures    float64
+	deliveryFailures map[string]float64 // by channel
+	deliveries       map[string]float64 // by channel
+}
+
+// NewExporter starts an exporter without any readings
+func NewExporter() *Exporter {
+	return &Exporter{readings: map[string]Reading{}, deliveryFailures: map[string]float64{}, deliveries: map[string]float64{}}
+}
+
+// Attach follows the events of a check's bus
+func (x *Exporter) Attach(bus *Bus) {
+	bus.Subscribe(x.record, EventReading, EventAlertSuppressed, EventFetchFailed, EventDelivered, EventDeliveryFailed)
+}
+
+// record folds an event into the metrics
+func (x *Exporter) record(e Event) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	switch e.Kind {
+	case EventReading, EventAlertSuppressed:
+		// Suppressed alerts still carry a fresh reading
+		if r := e.Alert.Reading; !r.Timestamp.IsZero() {
+			x.readings[r.Room] = r
+		}
+	case EventFetchFailed:
+		x.fetchFailures++
+	case EventDelivered:
+		x.deliveries[e.Channel]++
+	case EventDeliveryFailed:
+		x.deliveryFailures[e.Channel]++
+	}
+}
+
+// ServeHTTP writes the metrics
+func (x *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	rooms := make([]string, 0, len(x.readings))
+	for room := range x.readings {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	gauges := []struct {
+		name, help string
+		value      func(Reading) float64
+	}{
+		{"electricity_remaining_kwh", "Remaining balance of the room.", func(r Reading) float64 { return r.Remaining }},
+		{"electricity_used_kwh", "Electricity used of the quota.", func(r Reading) float64 { return r.Used }},
+		{"electricity_total_kwh", "Total quota of the room.", func(r Reading) float64 { return r.Total }},
+		{"electricity_last_success_timestamp_seconds", "Unix time of the last successful fetch.", func(r Reading) float64 {
+			return float64(r.Timestamp.UnixMilli()) / 1000
+		}},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, room := range rooms {
+			fmt.Fprintf(w, "%s{room=%q} %g\n", g.name, room, g.value(x.readings[room]))
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP electricity_fetch_failures_total Checks whose fetch failed after every retry.\n")
+	fmt.Fprintf(w, "# TYPE electricity_fetch_failures_total counter\nelectricity_fetch_failures_total %g\n", x.fetchFailures)
+	writeCounter(w, "electricity_notifications_total", "Notifications delivered by channel.", x.deliveries)
+	writeCounter(w, "electricity_notification_failures_total", "Notifications that failed by channel.", x.deliveryFailures)
+}
+
+// writeCounter writes a counter labelled by channel
+func writeCounter(w http.ResponseWriter, name, help string, values map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	channels := make([]string, 0, len(values))
+	for ch := range values {
+		channels = append(channels, ch)
+	}
+	sort.Strings(channels)
+	for _, ch := range channels {
+		fmt.Fprintf(w, "%s{channel=%q} %g\n", name, ch, values[ch])
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT publishes every reading to a broker, e.g. for Home Assistant or
+// Node-RED. Each publish uses its own short MQTT 3.1.1 connection of the
+// Eclipse Paho client.
+type MQTT struct {
+	Broker   string // tcp://host:1883, or ssl://host:8883 for TLS (mqtt:// and mqtts:// also work); disabled when empty
+	Topic    string `example:"cuhksz-electricity/{room}"` // {room} is replaced by the room, defaults to cuhksz-electricity/{room}
+	ClientID string // defaults to a random ID
+	Username string
+	Password string
+	QoS      int  `example:"1"`    // 0, 1 or 2
+	Retain   bool `example:"true"` // the broker keeps the latest reading for new subscribers
+}
+
+// Enabled reports whether a broker is configured
+func (m MQTT) Enabled() bool { return m.Broker != "" }
+
+// Validate checks the broker URL and QoS
+func (m MQTT) Validate() error {
+	if !m.Enabled() {
+		return nil
+	}
+	if _, err := m.address(); err != nil {
+		return err
+	}
+	if m.QoS < 0 || m.QoS > 2 {
+		return fmt.Errorf("invalid MQTT.QoS %d, want 0, 1 or 2", m.QoS)
+	}
+	if strings.ContainsAny(m.Topic, "+#") {
+		return fmt.Errorf("MQTT.Topic %q cannot contain wildcards", m.Topic)
+	}
+	return nil
+}
+
+// address normalizes the broker URL for the client, with the default
+// port filled in
+func (m MQTT) address() (string, error) {
+	u, err := url.Parse(m.Broker)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid MQTT.Broker %q, want e.g. tcp://host:1883", m.Broker)
+	}
+	scheme, port := "tcp", "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		scheme, port = "ssl", "8883"
+	default:
+		return "", fmt.Errorf("unknown MQTT.Broker scheme %q, use tcp or ssl", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return scheme + "://" + net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// topic is where the readings of room are published
+func (m MQTT) topic(room string) string {
+	topic := m.Topic
+	if topic == "" {
+		topic = "cuhksz-electricity/{room}"
+	}
+	return strings.TrimSuffix(strings.ReplaceAll(topic, "{room}", room), "/")
+}
+
+// publish sends the reading; failures are logged and do not affect the run
+func (m MQTT) publish(r Reading, label string) {
+	if !m.Enabled() {
+		return
+	}
+	body, err := json.Marshal(feedPayload{Reading: r, Label: label})
+	if err != nil {
+		log.Printf("Failed to encode reading for MQTT: %v", err)
+		return
+	}
+	room := label
+	if room == "" {
+		room = r.Room
+	}
+	if err := m.Publish(m.topic(room), body); err != nil {
+		log.Printf("Failed to publish reading to MQTT: %v", err)
+	}
+}
+
+// mqttTimeout bounds connecting and each acknowledgement
+const mqttTimeout = 10 * time.Second
+
+// Publish connects, publishes payload to topic with the configured QoS
+// and retain flag, waits for the broker's acknowledgement, and
+// disconnects
+func (m MQTT) Publish(topic string, payload []byte) error {
+	broker, err := m.address()
+	if err != nil {
+		return err
+	}
+	clientID := m.ClientID
+	if clientID == "" {
+		b := make([]byte, 4)
+		rand.Read(b)
+		clientID = "cuhksz-electricity-" + hex.EncodeToString(b)
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(m.Username).
+		SetPassword(m.Password).
+		SetCleanSession(true).
+		SetKeepAlive(time.Minute).
+		SetConnectTimeout(mqttTimeout).
+		SetWriteTimeout(mqttTimeout).
+		SetAutoReconnect(false).
+		SetConnectRetry(false)
+
+	client := mqtt.NewClient(opts)
+	if err := mqttWait(client.Connect()); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+	defer client.Disconnect(250)
+	if err := mqttWait(client.Publish(topic, byte(m.QoS), m.Retain, payload)); err != nil {
+		return fmt.Errorf("failed to publish to MQTT broker: %w", err)
+	}
+	return nil
+}
+
+// mqttWait waits for an MQTT operation within mqttTimeout
+func mqttWait(t mqtt.Token) error {
+	if !t.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("no answer within %s", mqttTimeout)
+	}
+	return t.Error()
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Outages configures the campus announcement watcher
+type Outages struct {
+	URL      string   // RSS or Atom feed of campus announcements
+	Keywords []string `example:"[停电, power outage, power cut]"` // outage words, defaults to 停电 / power outage / power cut
+	Building string   // defaults to RequestData.Build
+}
+
+// Notice is a single announcement
+type Notice struct {
+	Title string
+	Link  string
+	Body  string
+}
+
+// feed covers the parts of RSS 2.0 and Atom used here
+type feed struct {
+	Items []struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		GUID        string `xml:"guid"`
+		Description string `xml:"description"`
+	} `xml:"channel>item"`
+	Entries []struct {
+		Title string `xml:"title"`
+		Link  struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		ID      string `xml:"id"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+	} `xml:"entry"`
+}
+
+// Fetch downloads the announcement feed
+func (O *Outages) Fetch() ([]Notice, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(O.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch announcements: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("announcements returned status %d", resp.StatusCode)
+	}
+
+	var f feed
+	if err := xml.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse announcements feed: %w", err)
+	}
+	var notices []Notice
+	for _, it := range f.Items {
+		link := it.Link
+		if link == "" {
+			link = it.GUID
+		}
+		notices = append(notices, Notice{Title: it.Title, Link: link, Body: it.Description})
+	}
+	for _, e := range f.Entries {
+		link := e.Link.Href
+		if link == "" {
+			link = e.ID
+		}
+		notices = append(notices, Notice{Title: e.Title, Link: link, Body: e.Summary + e.Content})
+	}
+	return notices, nil
+}
+
+// Matches reports whether a notice announces an outage for the building
+func (O *Outages) Matches(n Notice, building string) bool {
+	keywords := O.Keywords
+	if len(keywords) == 0 {
+		keywords = []string{"停电", "power outage", "power cut"}
+	}
+	text := strings.ToLower(n.Title + " " + n.Body)
+	if building != "" && !strings.Contains(text, strings.ToLower(building)) {
+		return false
+	}
+	for _, k := range keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+const outagesSeenKey = "outages_seen"
+
+// CheckOutages forwards new outage notices for the building through the
+// notification channels and remembers them so each is sent once
+func (a *App) CheckOutages(building string) (int, error) {
+	if a.Outages.Building != "" {
+		building = a.Outages.Building
+	}
+	notices, err := a.Outages.Fetch()
+	if err != nil {
+		return 0, err
+	}
+
+	var seen []string
+	if _, err := a.State.Get(outagesSeenKey, &seen); err != nil {
+		return 0, err
+	}
+	known := map[string]bool{}
+	for _, s := range seen {
+		known[s] = true
+	}
+
+	sent := 0
+	for _, n := range notices {
+		key := n.Link
+		if key == "" {
+			key = n.Title
+		}
+		if known[key] || !a.Outages.Matches(n, building) {
+			continue
+		}
+		msg := "Warning: Planned power outage notice: " + n.Title
+		if n.Link != "" {
+			msg += "\n" + n.Link
+		}
+		if err := a.Notify(Alert{Message: msg, Rule: "outage"}); err != nil {
+			log.Printf("Failed to forward outage notice: %v", err)
+			continue
+		}
+		known[key] = true
+		seen = append(seen, key)
+		sent++
+	}
+	if sent > 0 {
+		if len(seen) > 200 {
+			seen = seen[len(seen)-200:]
+		}
+		return sent, a.State.Put(outagesSeenKey, seen)
+	}
+	return 0, nil
+}
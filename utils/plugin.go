@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Plugins configures out-of-process integrations.
+// Each executable in Dir speaks a JSON-over-stdio protocol: it reads one
+// request object from stdin and writes one response object to stdout.
+type Plugins struct {
+	Dir     string `example:"plugins"` // directory scanned for plugin executables
+	Fetcher string // name of a fetcher plugin that replaces RequestData
+	Timeout int    `example:"30"` // seconds a plugin may run per call, defaults to 30
+}
+
+// pluginRequest is sent to a plugin on stdin
+type pluginRequest struct {
+	Kind    string    `json:"kind"` // "describe", "notify", "fetch" or "history"
+	Message string    `json:"message,omitempty"`
+	Since   time.Time `json:"since,omitzero"` // history only
+	Until   time.Time `json:"until,omitzero"` // history only
+}
+
+// pluginResponse is read back from a plugin's stdout
+type pluginResponse struct {
+	Name    string   `json:"name,omitempty"`
+	Roles   []string `json:"roles,omitempty"` // "notifier", "fetcher" and/or "history"
+	Message string   `json:"message,omitempty"`
+	Used    float64  `json:"used,omitempty"`
+	Total   float64  `json:"total,omitempty"`
+	Room    string   `json:"room,omitempty"`
+	Error   string   `json:"error,omitempty"`
+
+	Readings []struct {
+		Used  float64   `json:"used"`
+		Total float64   `json:"total"`
+		Room  string    `json:"room"`
+		Time  time.Time `json:"time"`
+	} `json:"readings,omitempty"` // history only
+}
+
+// Plugin is an external binary acting as a notifier and/or fetcher
+type Plugin struct {
+	Path    string
+	Timeout time.Duration
+	name    string
+	roles   []string
+}
+
+// call runs the plugin once with req and decodes its answer
+func (p *Plugin) call(req pluginRequest) (res pluginResponse, err error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return res, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return res, fmt.Errorf("plugin %s failed: %w: %s", p.Path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		return res, fmt.Errorf("plugin %s returned invalid JSON: %w", p.Path, err)
+	}
+	if res.Error != "" {
+		return res, errors.New(res.Error)
+	}
+	return res, nil
+}
+
+// Name identifies the plugin by its self-reported name
+func (p *Plugin) Name() string { return p.name }
+
+// Has reports whether the plugin declared the given role
+func (p *Plugin) Has(role string) bool {
+	for _, r := range p.roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify implements Notifier by handing the message to the plugin
+func (p *Plugin) Notify(msg string) error {
+	_, err := p.call(pluginRequest{Kind: "notify", Message: msg})
+	return err
+}
+
+// GetMsg implements Fetcher by asking the plugin for the current reading
+func (p *Plugin) GetMsg() (Reading, error) {
+	res, err := p.call(pluginRequest{Kind: "fetch"})
+	if err != nil {
+		return Reading{}, err
+	}
+	return NewReading(res.Used, res.Total, res.Room), nil
+}
+
+// DiscoverPlugins describes every executable found in the plugins directory
+func (P *Plugins) DiscoverPlugins() ([]*Plugin, error) {
+	if P.Dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(P.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var plugins []*Plugin
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		p := &Plugin{
+			Path:    filepath.Join(P.Dir, entry.Name()),
+			Timeout: time.Duration(P.Timeout) * time.Second,
+		}
+		res, err := p.call(pluginRequest{Kind: "describe"})
+		if err != nil {
+			log.Printf("Skipping plugin %s: %v", p.Path, err)
+			continue
+		}
+		p.name, p.roles = res.Name, res.Roles
+		if p.name == "" {
+			p.name = entry.Name()
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
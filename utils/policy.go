@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Failure policies accepted in Policy.Failure
+const (
+	FailOnCritical    = "fail-on-critical"     // only critical channels (Telegram) are fatal, the default
+	FailOnAny         = "fail-on-any"          // any failed delivery is fatal
+	FailOnAllChannels = "fail-on-all-channels" // fatal only if no channel delivered
+	NeverFail         = "never-fail"           // always exit successfully
+)
+
+// Exit codes reported by the process
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitFetchFailed    = 2
+	ExitDeliveryFailed = 3
+	ExitBelowThreshold = 4 // query only: the reading matched a warning rule
+)
+
+// Policy decides which failures make a run fail
+type Policy struct {
+	Failure       string `example:"fail-on-critical"`
+	NotifyTimeout int    `example:"30"` // seconds each channel may take, defaults to 30
+	// FailureStreak is how many runs in a row must fail to fetch before
+	// the failure is reported, defaults to 1
+	FailureStreak int `example:"1"`
+}
+
+// DeliveryError lists the channels that failed to deliver
+type DeliveryError struct {
+	Failed []string
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("delivery failed for: %s", strings.Join(e.Failed, ", "))
+}
+
+// Validate rejects unknown policy names
+func (p Policy) Validate() error {
+	switch p.Failure {
+	case "", FailOnCritical, FailOnAny, FailOnAllChannels, NeverFail:
+		return nil
+	}
+	return fmt.Errorf("unknown failure policy %q", p.Failure)
+}
+
+// deliveryResult judges a notify round; failed includes critical ones
+func (p Policy) deliveryResult(attempted, failed, critical []string) error {
+	switch p.Failure {
+	case NeverFail:
+		return nil
+	case FailOnAny:
+		if len(failed) > 0 {
+			return &DeliveryError{Failed: failed}
+		}
+	case FailOnAllChannels:
+		if len(attempted) > 0 && len(failed) == len(attempted) {
+			return &DeliveryError{Failed: failed}
+		}
+	default:
+		if len(critical) > 0 {
+			return &DeliveryError{Failed: critical}
+		}
+	}
+	return nil
+}
+
+// fallbackResult judges a fallback chain, which only fails when every
+// attempted channel failed
+func (p Policy) fallbackResult(attempted, failed []string) error {
+	if p.Failure == NeverFail || len(failed) < len(attempted) {
+		return nil
+	}
+	return &DeliveryError{Failed: failed}
+}
+
+// fetchResult judges a failed fetch
+func (p Policy) fetchResult(err error) error {
+	if p.Failure == NeverFail {
+		return nil
+	}
+	return err
+}
+
+// recordFetch updates the failure streak after a fetch and returns its
+// length, 0 after a success
+func (a *App) recordFetch(ok bool) int {
+	if a.State == nil {
+		if ok {
+			return 0
+		}
+		return 1
+	}
+	key := a.roomKey("fetch_failures")
+	if ok {
+		if err := a.State.Delete(key); err != nil {
+			log.Printf("Failed to reset failure streak: %v", err)
+		}
+		return 0
+	}
+	var streak int
+	if _, err := a.State.Get(key, &streak); err != nil {
+		log.Printf("Failed to read failure streak: %v", err)
+	}
+	streak++
+	if err := a.State.Put(key, streak); err != nil {
+		log.Printf("Failed to save failure streak: %v", err)
+	}
+	return streak
+}
+
+// ExitCode maps a run error to the process exit code
+func ExitCode(err error) int {
+	var delivery *DeliveryError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrMaxRetries):
+		return ExitFetchFailed
+	case errors.As(err, &delivery):
+		return ExitDeliveryFailed
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PollConfig enables a Telegram poll asking roommates whether to top up
+// when the balance first turns into a warning
+type PollConfig struct {
+	Enabled  bool
+	ChatID   string   `example:"your-room-group-chat-id"` // room group chat, defaults to UserID
+	Question string   `example:"Top up 100 now?"`         // defaults to "Top up 100 now?"
+	Options  []string `example:"[Yes, No]"`               // defaults to Yes / No
+}
+
+// pollState tracks the poll for the current warning episode
+type pollState struct {
+	ChatID    string
+	MessageID int
+	Closed    bool
+	Result    string
+}
+
+const pollStateKey = "telegram_poll"
+
+// SendPoll posts a non-anonymous poll and returns its message ID
+func (T *Telegram) SendPoll(chatID, question string, options []string) (int, error) {
+	opts, err := json.Marshal(options)
+	if err != nil {
+		return 0, err
+	}
+	var msg struct {
+		MessageID int `json:"message_id"`
+	}
+	params := url.Values{
+		"chat_id":      {chatID},
+		"question":     {question},
+		"options":      {string(opts)},
+		"is_anonymous": {"false"},
+	}
+	T.setThread(params, chatID)
+	err = T.call("sendPoll", params, &msg)
+	return msg.MessageID, err
+}
+
+// StopPoll closes a poll and summarizes its votes
+func (T *Telegram) StopPoll(chatID string, messageID int) (string, error) {
+	var poll struct {
+		Options []struct {
+			Text       string `json:"text"`
+			VoterCount int    `json:"voter_count"`
+		} `json:"options"`
+	}
+	err := T.call("stopPoll", url.Values{
+		"chat_id":    {chatID},
+		"message_id": {strconv.Itoa(messageID)},
+	}, &poll)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, o := range poll.Options {
+		parts = append(parts, fmt.Sprintf("%s %d", o.Text, o.VoterCount))
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// applyPoll opens a top-up poll on the first warning of an episode and
+// reports its outcome in the follow-up reminders
+func (a *App) applyPoll(alert *Alert) {
+	if a.Poll == nil || !a.Poll.Poll.Enabled {
+		return
+	}
+	var st pollState
+	open, err := a.State.Get(pollStateKey, &st)
+	if err != nil {
+		log.Printf("Failed to read poll state: %v", err)
+		return
+	}
+
+	// The balance recovered, the next warning starts a new poll
+	if !alert.IsWarning() {
+		if open {
+			if err := a.State.Delete(pollStateKey); err != nil {
+				log.Printf("Failed to clear poll state: %v", err)
+			}
+		}
+		return
+	}
+
+	conf := a.Poll.Poll
+	switch {
+	case !open:
+		chatID, question, options := conf.ChatID, conf.Question, conf.Options
+		if chatID == "" {
+			chatID = a.Poll.UserID
+		}
+		if question == "" {
+			question = "Top up 100 now?"
+		}
+		if len(options) < 2 {
+			options = []string{"Yes", "No"}
+		}
+		id, err := a.Poll.SendPoll(chatID, question, options)
+		if err != nil {
+			log.Printf("Failed to send top-up poll: %v", err)
+			return
+		}
+		st = pollState{ChatID: chatID, MessageID: id}
+	case !st.Closed:
+		result, err := a.Poll.StopPoll(st.ChatID, st.MessageID)
+		if err != nil {
+			log.Printf("Failed to close top-up poll: %v", err)
+			return
+		}
+		st.Closed, st.Result = true, result
+	}
+	if st.Closed {
+		alert.Message += "\nTop-up poll: " + st.Result
+	}
+	if err := a.State.Put(pollStateKey, st); err != nil {
+		log.Printf("Failed to save poll state: %v", err)
+	}
+}
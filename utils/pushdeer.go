@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PushDeer pushes alerts through PushDeer, a free Apple push relay
+type PushDeer struct {
+	PushKey      string
+	Server       string // self-hosted endpoint, defaults to https://api2.pushdeer.com
+	WarningsOnly bool
+}
+
+// Name identifies the PushDeer channel
+func (P *PushDeer) Name() string { return "PushDeer" }
+
+// Notify implements Notifier by pushing the message; the first line is
+// the notification text, the rest the detail shown when opened
+func (P *PushDeer) Notify(msg string) error {
+	server := strings.TrimRight(P.Server, "/")
+	if server == "" {
+		server = "https://api2.pushdeer.com"
+	}
+	text, desp, _ := strings.Cut(msg, "\n")
+	var res struct {
+		Code  int    `json:"code"`
+		Error string `json:"error"`
+	}
+	err := postJSON(server+"/message/push", nil, map[string]string{
+		"pushkey": P.PushKey,
+		"text":    text,
+		"desp":    desp,
+		"type":    "markdown",
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("failed to send PushDeer push: %w", err)
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("PushDeer push failed: %d %s", res.Code, res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pushover pushes alerts through Pushover. An exceeded limit is sent
+// with emergency priority, which repeats until acknowledged on the phone.
+type Pushover struct {
+	Token        string // application API token
+	User         string // user or group key
+	Device       string // optional, limits delivery to one device
+	Retry        int    `example:"60"`   // seconds between emergency repeats, defaults to 60 (minimum 30)
+	Expire       int    `example:"3600"` // seconds emergency repeats last, defaults to 3600
+	APIHost      string // defaults to api.pushover.net
+	WarningsOnly bool
+}
+
+// Name identifies the Pushover channel
+func (P *Pushover) Name() string { return "Pushover" }
+
+// priority escalates with the alert level
+func (P *Pushover) priority(level string) int {
+	switch level {
+	case LevelCritical:
+		return 2
+	case LevelWarning:
+		return 1
+	}
+	return 0
+}
+
+// Notify implements Notifier for messages without an alert
+func (P *Pushover) Notify(msg string) error { return P.send(msg, MessageLevel(msg)) }
+
+// NotifyAlert implements AlertNotifier, prioritizing by the alert's level
+func (P *Pushover) NotifyAlert(alert Alert, msg string) error { return P.send(msg, alert.Severity()) }
+
+// send pushes msg with the priority of level
+func (P *Pushover) send(msg, level string) error {
+	host := P.APIHost
+	if host == "" {
+		host = "api.pushover.net"
+	}
+	title, body, _ := strings.Cut(msg, "\n")
+	if body == "" {
+		body = title
+	}
+	push := map[string]interface{}{
+		"token":    P.Token,
+		"user":     P.User,
+		"title":    title,
+		"message":  body,
+		"priority": P.priority(level),
+	}
+	if P.Device != "" {
+		push["device"] = P.Device
+	}
+	if push["priority"] == 2 {
+		retry, expire := P.Retry, P.Expire
+		if retry < 30 {
+			retry = 60
+		}
+		if expire <= 0 {
+			expire = 3600
+		}
+		push["retry"], push["expire"] = retry, expire
+	}
+
+	var res struct {
+		Status int      `json:"status"`
+		Errors []string `json:"errors"`
+	}
+	if err := postJSON(fmt.Sprintf("https://%s/1/messages.json", host), nil, push, &res); err != nil {
+		return fmt.Errorf("failed to send Pushover message: %w", err)
+	}
+	if res.Status != 1 {
+		return fmt.Errorf("Pushover failed: %s", strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Queue keeps warnings that could not be delivered and retries them on
+// later runs, so a short channel outage delays a warning instead of
+// dropping it. It needs State.File to survive between runs.
+type Queue struct {
+	MaxAge   int `example:"24"` // hours a queued warning is retried, defaults to 24
+	Disabled bool
+}
+
+// queued is one pending message for one channel
+type queued struct {
+	Channel  string
+	Message  string
+	Level    string // the alert's level, so custom prefixes still ring
+	Queued   time.Time
+	Attempts int
+}
+
+const queueKey = "delivery_queue"
+
+// maxAge is how long a queued warning stays relevant
+func (q Queue) maxAge() time.Duration {
+	if q.MaxAge <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(q.MaxAge) * time.Hour
+}
+
+// enqueue stores failed warning deliveries for a later run and returns
+// the jobs it did not keep
+func (a *App) enqueue(jobs []delivery) []delivery {
+	if a.Queue.Disabled || a.State == nil {
+		return jobs
+	}
+	var pending []queued
+	if _, err := a.State.Get(queueKey, &pending); err != nil {
+		log.Printf("Failed to read delivery queue: %v", err)
+		return jobs
+	}
+	var added, rest []delivery
+	for _, job := range jobs {
+		if !job.warning() {
+			rest = append(rest, job)
+			continue
+		}
+		added = append(added, job)
+		pending = append(pending, queued{Channel: job.Name(), Message: job.msg, Level: job.level(), Queued: a.Clock.Now(), Attempts: 1})
+	}
+	if len(added) == 0 {
+		return rest
+	}
+	if err := a.State.Put(queueKey, pending); err != nil {
+		log.Printf("Failed to save delivery queue: %v", err)
+		return jobs
+	}
+	for _, job := range added {
+		fmt.Printf("%s notification queued for the next run\n", job.Name())
+	}
+	return rest
+}
+
+// shelve keeps failed deliveries in one place: warnings in the queue,
+// the rest in the dead-letter file
+func (a *App) shelve(jobs []delivery) {
+	a.deadLetter(a.enqueue(jobs))
+}
+
+// FlushQueue retries queued warnings, dropping those that expired
+func (a *App) FlushQueue() {
+	if a.Queue.Disabled || a.State == nil {
+		return
+	}
+	var pending []queued
+	if ok, err := a.State.Get(queueKey, &pending); err != nil || !ok || len(pending) == 0 {
+		if err != nil {
+			log.Printf("Failed to read delivery queue: %v", err)
+		}
+		return
+	}
+
+	now := a.Clock.Now()
+	timeout := a.notifyTimeout()
+	var keep []queued
+	for _, q := range pending {
+		if now.Sub(q.Queued) > a.Queue.maxAge() {
+			log.Printf("Dropping queued %s notification from %s: expired", q.Channel, q.Queued.Format(time.RFC3339))
+			continue
+		}
+		ch, ok := a.channel(q.Channel)
+		if !ok {
+			continue
+		}
+		if _, quiet := a.QuietHours.Until(q.Channel, now); quiet {
+			keep = append(keep, q)
+			continue
+		}
+		msg := fmt.Sprintf("%s\n(delayed, first attempt at %s)", q.Message, a.Locale.DateTime(q.Queued))
+		alert := &Alert{Message: msg, Level: q.Level, Time: now}
+		job := delivery{Channel: ch, msg: msg, alert: alert}
+		a.send(&job, timeout)
+		if job.err != nil {
+			q.Attempts++
+			keep = append(keep, q)
+			continue
+		}
+		a.publish(Event{Kind: EventDelivered, Alert: *alert, Channel: q.Channel})
+		fmt.Printf("Queued %s notification sent successfully: %s\n", q.Channel, q.Message)
+	}
+	if err := a.State.Put(queueKey, keep); err != nil {
+		log.Printf("Failed to save delivery queue: %v", err)
+	}
+}
+
+// channel finds a configured channel by name
+func (a *App) channel(name string) (Channel, bool) {
+	for _, ch := range a.Channels {
+		if strings.EqualFold(ch.Name(), name) {
+			return ch, true
+		}
+	}
+	return Channel{}, false
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours keeps channels silent during chosen hours of the day, e.g.
+// no SMS at night while Telegram is always allowed. Warnings sent while
+// a channel is quiet wait in the delivery queue for the next run after
+// the quiet hours; other messages are skipped for that channel.
+type QuietHours struct {
+	Default  string            // window such as 23:00-08:00 for every channel, empty means none
+	Channels map[string]string `example:"{Telegram: ''}"` // window per channel name, overriding Default; "" always allows the channel
+}
+
+// Validate checks every configured window
+func (q QuietHours) Validate() error {
+	windows := []string{q.Default}
+	for _, w := range q.Channels {
+		windows = append(windows, w)
+	}
+	for _, w := range windows {
+		if _, _, err := parseWindow(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// window returns the quiet window configured for a channel
+func (q QuietHours) window(channel string) string {
+	for name, w := range q.Channels {
+		if strings.EqualFold(name, channel) {
+			return w
+		}
+	}
+	return q.Default
+}
+
+// Until reports whether the channel is quiet at now and when that ends
+func (q QuietHours) Until(channel string, now time.Time) (time.Time, bool) {
+	return windowUntil(q.window(channel), now)
+}
+
+// windowUntil reports whether now falls in the HH:MM-HH:MM window and
+// when that ends; an invalid window never applies
+func windowUntil(w string, now time.Time) (time.Time, bool) {
+	from, to, err := parseWindow(w)
+	if err != nil || from == to {
+		return time.Time{}, false
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	minute := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	switch {
+	case from < to && minute >= from && minute < to:
+		return day.Add(to), true
+	case from > to && minute >= from:
+		return day.AddDate(0, 0, 1).Add(to), true
+	case from > to && minute < to:
+		return day.Add(to), true
+	}
+	return time.Time{}, false
+}
+
+// parseWindow parses HH:MM-HH:MM into offsets from midnight; an empty
+// window parses as never quiet
+func parseWindow(w string) (from, to time.Duration, err error) {
+	if w == "" {
+		return 0, 0, nil
+	}
+	start, end, ok := strings.Cut(w, "-")
+	if ok {
+		if from, err = clockOffset(strings.TrimSpace(start)); err == nil {
+			to, err = clockOffset(strings.TrimSpace(end))
+		}
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, want HH:MM-HH:MM", w)
+	}
+	return from, to, nil
+}
+
+// clockOffset parses HH:MM into an offset from midnight
+func clockOffset(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// holdQuiet takes the jobs for channels in their quiet hours out of the
+// delivery, queueing the warnings among them
+func (a *App) holdQuiet(jobs []delivery) []delivery {
+	if a.QuietHours.Default == "" && len(a.QuietHours.Channels) == 0 {
+		return jobs
+	}
+	now := a.Clock.Now()
+	var send, held []delivery
+	for _, job := range jobs {
+		until, quiet := a.QuietHours.Until(job.Name(), now)
+		if !quiet {
+			send = append(send, job)
+			continue
+		}
+		fmt.Printf("%s is in quiet hours until %s\n", job.Name(), until.Format("15:04"))
+		held = append(held, job)
+	}
+	a.enqueue(held)
+	return send
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWindowUntil(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2025, 3, 1, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		window string
+		now    time.Time
+		quiet  bool
+		until  time.Time
+	}{
+		{"", day(3, 0), false, time.Time{}},
+		{"09:00-17:00", day(8, 59), false, time.Time{}},
+		{"09:00-17:00", day(9, 0), true, day(17, 0)},
+		{"09:00-17:00", day(17, 0), false, time.Time{}},
+		{"23:00-07:00", day(22, 59), false, time.Time{}},
+		{"23:00-07:00", day(23, 30), true, day(7, 0).AddDate(0, 0, 1)},
+		{"23:00-07:00", day(6, 59), true, day(7, 0)},
+		{"23:00-07:00", day(7, 0), false, time.Time{}},
+		{"08:00-08:00", day(8, 0), false, time.Time{}},
+		{"bogus", day(8, 0), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		until, quiet := windowUntil(tt.window, tt.now)
+		if quiet != tt.quiet || !until.Equal(tt.until) {
+			t.Errorf("windowUntil(%q, %s) = %s, %v; want %s, %v",
+				tt.window, tt.now.Format("15:04"), until, quiet, tt.until, tt.quiet)
+		}
+	}
+}
+
+func TestQuietHoursValidate(t *testing.T) {
+	if err := (QuietHours{Default: "23:00-07:00", Channels: map[string]string{"SMS": ""}}).Validate(); err != nil {
+		t.Errorf("valid quiet hours rejected: %v", err)
+	}
+	for _, w := range []string{"23:00", "23:00-7", "25:00-07:00"} {
+		if err := (QuietHours{Channels: map[string]string{"SMS": w}}).Validate(); err == nil {
+			t.Errorf("invalid window %q accepted", w)
+		}
+	}
+}
+
+func TestQuietHoursQueue(t *testing.T) {
+	clock := &FakeClock{T: time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)}
+	var state StateConfig
+	st, err := state.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram, sms := &recorder{name: "Telegram"}, &recorder{name: "SMS"}
+	a := &App{
+		Clock:      clock,
+		State:      st,
+		Channels:   []Channel{{Notifier: telegram}, {Notifier: sms}},
+		QuietHours: QuietHours{Default: "22:00-08:00", Channels: map[string]string{"telegram": ""}},
+	}
+
+	// At night only Telegram is told; the warning waits for SMS and
+	// the normal reading is dropped for it
+	if err := a.Notify(warningAlert); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Notify(normalAlert); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := telegram.messages(), []string{warningAlert.Message, normalAlert.Message}; !slices.Equal(got, want) {
+		t.Errorf("Telegram got %q, want %q", got, want)
+	}
+	if got := sms.messages(); len(got) != 0 {
+		t.Errorf("SMS got %q during quiet hours", got)
+	}
+
+	// Still quiet: the queue keeps the warning
+	clock.Sleep(6 * time.Hour)
+	a.FlushQueue()
+	if got := sms.messages(); len(got) != 0 {
+		t.Errorf("SMS got %q before the quiet hours ended", got)
+	}
+
+	// In the morning the warning is delivered once, marked as delayed
+	clock.Sleep(3 * time.Hour)
+	a.FlushQueue()
+	a.FlushQueue()
+	got := sms.messages()
+	if len(got) != 1 || !strings.HasPrefix(got[0], warningAlert.Message) || !strings.Contains(got[0], "delayed") {
+		t.Errorf("SMS got %q after the quiet hours, want the delayed warning once", got)
+	}
+}
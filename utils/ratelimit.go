@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit caps the requests to the upstream API from every trigger in
+// a process (scheduled checks, Telegram buttons, API checks), and lets
+// triggers close together share one reading
+type RateLimit struct {
+	PerMinute float64 `example:"6"`  // upstream requests per minute, 0 means unlimited
+	Burst     int     `example:"3"`  // requests allowed at once after a quiet spell, defaults to 1
+	Cache     int     `example:"30"` // seconds a fetched reading is reused for, 0 always fetches
+}
+
+// Enabled reports whether fetches are limited or cached
+func (R RateLimit) Enabled() bool {
+	return R.PerMinute > 0 || R.Cache > 0
+}
+
+// Limiter is a token bucket with a short-lived cache of readings, shared
+// by the apps of a process
+type Limiter struct {
+	conf RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	filled time.Time
+	cache  map[string]cachedReading
+}
+
+// cachedReading is a reading and when it was fetched
+type cachedReading struct {
+	reading Reading
+	at      time.Time
+}
+
+// NewLimiter starts a full bucket
+func NewLimiter(conf RateLimit) *Limiter {
+	return &Limiter{conf: conf, tokens: float64(conf.burst()), cache: map[string]cachedReading{}}
+}
+
+// burst is the bucket size
+func (R RateLimit) burst() int {
+	if R.Burst < 1 {
+		return 1
+	}
+	return R.Burst
+}
+
+// take waits for a token; the caller holds the lock, so waiting fetches
+// queue up and can then use what the first one fetched
+func (l *Limiter) take() {
+	if l.conf.PerMinute <= 0 {
+		return
+	}
+	perToken := time.Duration(float64(time.Minute) / l.conf.PerMinute)
+	for {
+		now := time.Now()
+		if !l.filled.IsZero() {
+			l.tokens += float64(now.Sub(l.filled)) / float64(perToken)
+		}
+		l.tokens = min(l.tokens, float64(l.conf.burst()))
+		l.filled = now
+		if l.tokens >= 1 {
+			l.tokens--
+			return
+		}
+		time.Sleep(time.Duration((1 - l.tokens) * float64(perToken)))
+	}
+}
+
+// fetch returns the cached reading under key while fresh, otherwise
+// fetches through the bucket and caches the result. A nil Limiter
+// always fetches.
+func (l *Limiter) fetch(key string, get func() (Reading, error)) (Reading, error) {
+	if l == nil {
+		return get()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	maxAge := time.Duration(l.conf.Cache) * time.Second
+	if c, ok := l.cache[key]; ok && time.Since(c.at) < maxAge {
+		return c.reading, nil
+	}
+	l.take()
+	r, err := get()
+	if err == nil && maxAge > 0 {
+		l.cache[key] = cachedReading{reading: r, at: time.Now()}
+	}
+	return r, err
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// Reading is one measurement of a room's electricity balance
+type Reading struct {
+	Used      float64   `json:"used"`
+	Total     float64   `json:"total"`
+	Remaining float64   `json:"remaining"`
+	Timestamp time.Time `json:"timestamp"`
+	Room      string    `json:"room"`
+	// MeterTime is when the meter itself was last read, if the API says;
+	// Timestamp is when the reading was fetched
+	MeterTime time.Time `json:"meter_time,omitzero"`
+}
+
+// NewReading builds a reading from the used and total amounts
+func NewReading(used, total float64, room string) Reading {
+	return Reading{Used: used, Total: total, Remaining: total - used, Room: room}
+}
+
+// Rounding smooths float noise in the meter values
+type Rounding struct {
+	Decimals int     `example:"2"`    // round used and total to this many places, 0 keeps them
+	Clamp    float64 `example:"0.01"` // a remaining within ±Clamp counts as 0, defaults to 0.01
+}
+
+// Apply rounds the reading and clamps a near-zero remaining balance, so
+// -0.003 is not reported as an exceeded limit
+func (R Rounding) Apply(r Reading) Reading {
+	if R.Decimals > 0 {
+		scale := math.Pow(10, float64(R.Decimals))
+		r.Used = math.Round(r.Used*scale) / scale
+		r.Total = math.Round(r.Total*scale) / scale
+		r.Remaining = math.Round((r.Total-r.Used)*scale) / scale
+	}
+	clamp := R.Clamp
+	if clamp == 0 {
+		clamp = 0.01
+	}
+	if math.Abs(r.Remaining) < clamp {
+		r.Remaining = 0
+	}
+	return r
+}
+
+// Vars exposes the reading to rules and templates
+func (r Reading) Vars() map[string]interface{} {
+	meterAge := -1.0
+	if age, ok := r.MeterAge(); ok {
+		meterAge = age.Hours()
+	}
+	return map[string]interface{}{
+		"used":      r.Used,
+		"total":     r.Total,
+		"remaining": r.Remaining,
+		"room":      r.Room,
+		"timestamp": r.Timestamp.Format("2006-01-02 15:04"),
+		"hour":      r.Timestamp.Hour(),
+		"weekday":   int(r.Timestamp.Weekday()),
+		"meter_age": meterAge,
+	}
+}
+
+// ConsumptionRate returns the units used per day between the oldest
+// reading in history and current. It reports false when history does
+// not reach back far enough to say.
+func ConsumptionRate(history []Reading, current Reading) (float64, bool) {
+	if len(history) == 0 {
+		return 0, false
+	}
+	oldest := history[0]
+	elapsed := current.Timestamp.Sub(oldest.Timestamp)
+	if elapsed < time.Hour {
+		return 0, false
+	}
+	return (current.Used - oldest.Used) / elapsed.Hours() * 24, true
+}
+
+// lastBefore returns the last reading in history taken before r
+func lastBefore(history []Reading, r Reading) (Reading, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Timestamp.Before(r.Timestamp) {
+			return history[i], true
+		}
+	}
+	return Reading{}, false
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DayUsage is the consumption attributed to one calendar day
+type DayUsage struct {
+	Date time.Time // midnight of the day
+	Used float64
+}
+
+// DailyUsage splits the growth of Used between consecutive readings into
+// per-day totals, attributing each step to the day of the later reading
+func DailyUsage(history []Reading) []DayUsage {
+	var days []DayUsage
+	for i := 1; i < len(history); i++ {
+		delta := history[i].Used - history[i-1].Used
+		if delta < 0 {
+			continue // counter reset, e.g. a new billing period
+		}
+		t := history[i].Timestamp
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		if n := len(days); n > 0 && days[n-1].Date.Equal(day) {
+			days[n-1].Used += delta
+			continue
+		}
+		days = append(days, DayUsage{Date: day, Used: delta})
+	}
+	return days
+}
+
+// Report summarizes consumption over the last days, with weather
+// insights when enabled
+func (a *App) Report(days int) (string, error) {
+	now := a.Clock.Now()
+	history, err := a.Store.History(now.AddDate(0, 0, -days))
+	if err != nil {
+		return "", err
+	}
+	usage := DailyUsage(history)
+	if len(usage) == 0 {
+		return "", errors.New("not enough history for a report")
+	}
+
+	var total float64
+	low := history[0].Remaining
+	for _, r := range history {
+		if r.Remaining < low {
+			low = r.Remaining
+		}
+	}
+	peak := usage[0]
+	for _, d := range usage {
+		total += d.Used
+		if d.Used > peak.Used {
+			peak = d
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Report for the last %d days:\n", days)
+	fmt.Fprintf(&sb, "Used %.2f in total, %.2f per day on average.\n", total, total/float64(len(usage)))
+	fmt.Fprintf(&sb, "Busiest day: %s with %.2f.\n", a.Locale.Day(peak.Date), peak.Used)
+	fmt.Fprintf(&sb, "Remaining: %.2f", history[len(history)-1].Remaining)
+
+	if a.Pricing.Enabled() {
+		soFar, projected, err := a.MonthCost()
+		if err != nil {
+			log.Printf("Failed to estimate cost: %v", err)
+		} else {
+			fmt.Fprintf(&sb, "\nCost this month: %s so far, about %s expected.",
+				a.Pricing.Format(soFar), a.Pricing.Format(projected))
+		}
+	}
+
+	if goals := a.GoalProgress(low); goals != "" {
+		sb.WriteString("\n" + goals)
+	}
+
+	if a.Weather.Enabled {
+		line, err := a.Weather.Insight(usage)
+		if err != nil {
+			log.Printf("Failed to build weather insight: %v", err)
+		} else if line != "" {
+			sb.WriteString("\n" + line)
+		}
+	}
+	return sb.String(), nil
+}
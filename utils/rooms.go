@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// RoomEntry is an additional monitored room. Empty fields inherit from
+// RequestData, so usually only Name, Build, Room and RoomID are set.
+type RoomEntry struct {
+	Name string
+	RequestData
+	Recipients *Recipients // checked on every run and notified separately
+	Store      StoreConfig // the room's own history, e.g. for charts
+}
+
+// Recipients routes a room's alerts to its own household
+type Recipients struct {
+	Channels       []string // channel names, empty means every channel
+	TelegramChatID string   // replaces Telegram.UserID
+	Email          string   // replaces Email.User
+}
+
+// Room is a monitored room with its own fetcher
+type Room struct {
+	Name       string
+	Fetcher    Fetcher
+	Recipients *Recipients
+	Store      Store // nil keeps no history
+}
+
+// Batch configures the consolidated many-room report
+type Batch struct {
+	Threshold float64 `example:"20"` // rooms at or below this remaining are listed, defaults to 20
+}
+
+// Resolve fills the entry's empty fields from the shared request data
+func (e RoomEntry) Resolve(base RequestData) RequestData {
+	rd := e.RequestData
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&rd.API, base.API}, {&rd.Text, base.Text}, {&rd.Campus, base.Campus},
+		{&rd.Source, base.Source}, {&rd.Build, base.Build}, {&rd.Room, base.Room},
+		{&rd.RoomID, base.RoomID}, {&rd.Lang, base.Lang}, {&rd.Terminal, base.Terminal},
+		{&rd.HistoryAPI, base.HistoryAPI},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	if rd.ID == 0 {
+		rd.ID = base.ID
+	}
+	if rd.Headers == nil {
+		rd.Headers = base.Headers
+	}
+	return rd
+}
+
+// Label names the room: Name, or its building and room number
+func (e RoomEntry) Label(base RequestData) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	rd := e.Resolve(base)
+	return strings.TrimSpace(rd.Build + " " + rd.Room)
+}
+
+// Room builds the room's fetcher, a fake one for fake:// addresses
+func (e RoomEntry) Room(base RequestData) (Room, error) {
+	rd := e.Resolve(base)
+	store, err := e.Store.Open()
+	if err != nil {
+		return Room{}, err
+	}
+	name := e.Label(base)
+	if IsFake(rd.API) {
+		f, err := NewFakeFetcher(rd.API)
+		if err != nil {
+			return Room{}, fmt.Errorf("room %s: %w", name, err)
+		}
+		return Room{Name: name, Fetcher: f, Recipients: e.Recipients, Store: store}, nil
+	}
+	return Room{Name: name, Fetcher: &rd, Recipients: e.Recipients, Store: store}, nil
+}
+
+// Select returns the channels for the recipients, with the Telegram chat
+// and email address replaced where given
+func (r *Recipients) Select(channels []Channel) []Channel {
+	var out []Channel
+	for _, ch := range channels {
+		// A household's alerts reach public channels only when listed
+		if !(Alert{Channels: r.Channels}).Targets(ch.Name()) || (ch.Public && len(r.Channels) == 0) {
+			continue
+		}
+		switch n := ch.Notifier.(type) {
+		case *Telegram:
+			if r.TelegramChatID != "" {
+				t := *n
+				// The topic belongs to the replaced group
+				t.UserID, t.ChatIDs, t.ThreadID = r.TelegramChatID, nil, 0
+				ch.Notifier = &t
+			}
+		case *Email:
+			if r.Email != "" {
+				e := *n
+				e.User, e.To, e.Cc = r.Email, nil, nil
+				ch.Notifier = &e
+			}
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// MultiRoom selects how rooms without their own recipients are reported
+// on every run; by default they only appear in the batch summary
+type MultiRoom struct {
+	Notify string // "", "per-room" or "combined"
+}
+
+// Multi-room notification modes accepted in MultiRoom.Notify
+const (
+	PerRoom  = "per-room" // one message per room, labelled with the room
+	Combined = "combined" // one message covering every room
+)
+
+// RunAll runs the check for the main room and the rooms selected by
+// MultiRoom, then for every room with its own recipients, so households
+// sharing an instance only see their room
+func (a *App) RunAll() error {
+	a.startBatch()
+	var errs []error
+	if a.MultiRoom.Notify == Combined {
+		errs = append(errs, a.runCombined())
+	} else {
+		errs = append(errs, a.Run())
+	}
+	for _, room := range a.Rooms {
+		if room.Recipients == nil && a.MultiRoom.Notify != PerRoom {
+			continue
+		}
+		sub := a.roomApp(room)
+		if room.Recipients != nil {
+			sub.Channels = room.Recipients.Select(a.Channels)
+		}
+		if err := sub.Run(); err != nil {
+			log.Printf("Room %s: %v", room.Name, err)
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, a.flushBatch())
+	return errors.Join(errs...)
+}
+
+// roomApp derives the pipeline for one of the further rooms
+func (a *App) roomApp(room Room) *App {
+	sub := *a
+	sub.Fetcher = room.Fetcher
+	sub.Label = room.Name
+	// Keep the main room's history, queue and polls to itself
+	sub.Store = room.Store
+	if sub.Store == nil {
+		sub.Store = NopStore{}
+	}
+	sub.Queue.Disabled = true
+	sub.Poll = nil
+	sub.Rooms = nil
+	return &sub
+}
+
+// RoomApps derives the pipelines of the further rooms by name, e.g. for
+// serving them next to the main room
+func (a *App) RoomApps() map[string]*App {
+	apps := map[string]*App{}
+	for _, room := range a.Rooms {
+		apps[room.Name] = a.roomApp(room)
+	}
+	return apps
+}
+
+// runCombined checks the main room and every room without its own
+// recipients and sends a single message covering all of them
+func (a *App) runCombined() error {
+	rooms := []*App{a}
+	for _, room := range a.Rooms {
+		if room.Recipients == nil {
+			rooms = append(rooms, a.roomApp(room))
+		}
+	}
+
+	var lines []string
+	warning := false
+	for i, r := range rooms {
+		label := r.Label
+		if label == "" {
+			label = fmt.Sprintf("Room %d", i+1)
+		}
+		reading, err := r.Fetch()
+		streak := r.recordFetch(err == nil)
+		if err != nil {
+			r.publish(Event{Kind: EventFetchFailed, Err: err})
+			if streak < r.Policy.FailureStreak {
+				log.Printf("%s: fetch failed %d of %d times in a row before alerting", label, streak, r.Policy.FailureStreak)
+				continue
+			}
+			if streak > 1 {
+				err = fmt.Errorf("%w (failed %d runs in a row)", err, streak)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %v", label, err))
+			continue
+		}
+		alert, ok, err := r.Check(reading, false)
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: implausible reading ignored", label))
+			if err != nil {
+				log.Print(err)
+			}
+			continue
+		}
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s: %v", label, err))
+			continue
+		}
+		r.applyStaleness(&alert)
+		r.publish(Event{Kind: EventReading, Alert: alert})
+		warning = warning || alert.IsWarning()
+		lines = append(lines, fmt.Sprintf("%s: %s", label, alert.Message))
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	msg := strings.Join(lines, "\n")
+	if warning {
+		msg = "Warning: some rooms need attention\n" + msg
+	}
+	return a.Notify(Alert{Message: msg, Rule: "combined"})
+}
+
+// UnmarshalJSON also accepts RequestData as a list of rooms: the first
+// is the main room, the others become Rooms and inherit its fields
+func (c *Config) UnmarshalJSON(b []byte) error {
+	type plain Config
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	var list []RoomEntry
+	for key, raw := range fields {
+		if strings.EqualFold(key, "RequestData") && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return fmt.Errorf("invalid RequestData list: %w", err)
+			}
+			if len(list) == 0 {
+				return errors.New("RequestData list is empty")
+			}
+			delete(fields, key)
+			rest, err := json.Marshal(fields)
+			if err != nil {
+				return err
+			}
+			b = rest
+		}
+	}
+	if err := json.Unmarshal(b, (*plain)(c)); err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		c.RequestData = list[0].RequestData
+		c.Rooms = append(list[1:], c.Rooms...)
+	}
+	c.shareEncryption()
+	return nil
+}
+
+// BatchSummary fetches every room and lists those at or below the
+// threshold, lowest first, in a single message
+func (a *App) BatchSummary() (Alert, error) {
+	if len(a.Rooms) == 0 {
+		return Alert{}, fmt.Errorf("no rooms configured")
+	}
+	threshold := a.Batch.Threshold
+	if threshold == 0 {
+		threshold = 20
+	}
+
+	type low struct {
+		name      string
+		remaining float64
+	}
+	var lows []low
+	var failed []string
+	for _, room := range a.Rooms {
+		var reading Reading
+		err := a.retry(func() (err error) {
+			reading, err = a.Limiter.fetch("reading:"+room.Name, func() (Reading, error) {
+				return a.Inject.fetch(room.Fetcher.GetMsg)
+			})
+			return
+		})
+		if err != nil {
+			log.Printf("Failed to fetch room %s: %v", room.Name, err)
+			failed = append(failed, room.Name)
+			continue
+		}
+		if reading.Remaining <= threshold {
+			lows = append(lows, low{room.Name, reading.Remaining})
+		}
+	}
+	sort.SliceStable(lows, func(i, j int) bool { return lows[i].remaining < lows[j].remaining })
+
+	var sb strings.Builder
+	if len(lows) > 0 {
+		fmt.Fprintf(&sb, "Warning: %d of %d rooms at or below %.2f:", len(lows), len(a.Rooms), threshold)
+	} else {
+		fmt.Fprintf(&sb, "All %d rooms above %.2f.", len(a.Rooms), threshold)
+	}
+	for _, l := range lows {
+		fmt.Fprintf(&sb, "\n%s %.2f", l.name, l.remaining)
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&sb, "\nFetch failed: %s", strings.Join(failed, ", "))
+	}
+	return Alert{Message: sb.String(), Rule: "batch"}, nil
+}
+
+// PublicChannels configures semi-public channels, such as a floor-wide
+// group, which get the public rendering of alerts without the balance
+type PublicChannels struct {
+	TelegramChatID string   // group chat, added as the Telegram-public channel
+	Channels       []string // further channels to treat as public, e.g. plugins
+}
+
+// renamed delivers through a notifier under another channel name
+type renamed struct {
+	Notifier
+	name string
+}
+
+func (r renamed) Name() string { return r.name }
+
+// Apply marks the listed channels public and adds the public Telegram chat
+func (p PublicChannels) Apply(channels []Channel) []Channel {
+	for i, ch := range channels {
+		if len(p.Channels) > 0 && (Alert{Channels: p.Channels}).Targets(ch.Name()) {
+			channels[i].Public = true
+		}
+	}
+	if p.TelegramChatID == "" {
+		return channels
+	}
+	group := (&Recipients{Channels: []string{"Telegram"}, TelegramChatID: p.TelegramChatID}).Select(channels)
+	if len(group) == 0 {
+		return channels
+	}
+	ch := group[0]
+	ch.Notifier = renamed{Notifier: ch.Notifier, name: "Telegram-public"}
+	ch.Public, ch.Critical, ch.WarningsOnly = true, false, false
+	return append(channels, ch)
+}
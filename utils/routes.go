@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Route sends a rule's alerts to other channels at certain times, e.g.
+// the group chat on weekday daytimes and the personal chat otherwise
+type Route struct {
+	Days   []string // weekday names such as Mon or Saturday, "weekdays" or "weekends"; empty means every day
+	Hours  string   // HH:MM-HH:MM, may span midnight; empty means all day
+	Notify []string // channel names used instead of the rule's Notify
+}
+
+// dayAliases expand to several weekdays in Route.Days
+var dayAliases = map[string][]time.Weekday{
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// parseDays resolves weekday names and aliases
+func parseDays(names []string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, name := range names {
+		if alias, ok := dayAliases[strings.ToLower(name)]; ok {
+			for _, d := range alias {
+				days[d] = true
+			}
+			continue
+		}
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(name, d.String()) || strings.EqualFold(name, d.String()[:3]) {
+				days[d], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown day %q", name)
+		}
+	}
+	return days, nil
+}
+
+// Validate checks the days and hours
+func (r Route) Validate() error {
+	if _, err := parseDays(r.Days); err != nil {
+		return err
+	}
+	_, _, err := parseWindow(r.Hours)
+	return err
+}
+
+// Matches reports whether the route applies at t, by the day t falls on
+func (r Route) Matches(t time.Time) bool {
+	days, err := parseDays(r.Days)
+	if err != nil || (len(days) > 0 && !days[t.Weekday()]) {
+		return false
+	}
+	if r.Hours == "" {
+		return true
+	}
+	_, in := windowUntil(r.Hours, t)
+	return in
+}
+
+// route returns the channels of the rule's first route matching t
+func (rs *RuleSet) route(rule string, t time.Time) ([]string, bool) {
+	for _, r := range rs.rules {
+		if r.Name != rule {
+			continue
+		}
+		for _, route := range r.Routes {
+			if route.Matches(t) {
+				return route.Notify, true
+			}
+		}
+		break
+	}
+	return nil, false
+}
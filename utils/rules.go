@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// Rule maps a condition on a reading to the message and channels to use.
+// When is a Starlark expression over the reading variables (remaining,
+// used, total, room, hour, weekday); && and || alias and / or.
+type Rule struct {
+	Name     string
+	When     string
+	Notify   []string // channel names, empty means every channel
+	Template string   // name of an entry in Templates
+	Public   string   // template for public channels, defaults to "public"
+	Routes   []Route  // first route matching the reading's time overrides Notify
+	Level    string   // normal, info, warning or critical; recognized from the message when empty
+}
+
+// Alert is a rendered message together with the channels it targets
+type Alert struct {
+	ID       string // short correlation ID shown in every channel and the audit log
+	Message  string
+	Public   string   // rendering for public channels, which skip the alert when empty
+	Channels []string // empty means every channel
+	Rule     string
+	Level    string // set by the rule, see Severity
+	Reading  Reading
+	Vars     map[string]interface{} `json:"-"` // variables the rules saw
+	Time     time.Time              `json:"-"` // when it is sent, by the app clock
+}
+
+// NewAlertID returns a short random correlation ID such as 7f3a
+func NewAlertID() string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Targets reports whether the alert should go to the named channel
+func (a Alert) Targets(channel string) bool {
+	if len(a.Channels) == 0 {
+		return true
+	}
+	for _, c := range a.Channels {
+		if strings.EqualFold(c, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTemplates reproduce the historical message wording
+var DefaultTemplates = map[string]string{
+	"exceeded": `Warning: Exceeded limit by {{printf "%.2f" (abs .remaining)}}!`,
+	"low":      `Warning: Remaining electricity is low: {{printf "%.2f" .remaining}}`,
+	"normal":   `Remaining electricity: {{printf "%.2f" .remaining}}`,
+	// public leaves out the balance, for group chats
+	"public": `{{if eq .rule "exceeded"}}Room {{.room}} has exceeded its electricity limit` +
+		`{{else if eq .rule "low"}}Room {{.room}} is running low on electricity` +
+		`{{else if eq .rule "normal"}}Room {{.room}} electricity is fine` +
+		`{{else}}Room {{.room}} electricity: {{.rule}}{{end}}`,
+}
+
+// DefaultRules reproduce the historical warning threshold, 20 units
+// unless Settings.Threshold says otherwise
+var DefaultRules = []Rule{
+	{Name: "exceeded", When: "remaining < 0", Template: "exceeded", Level: LevelCritical},
+	{Name: "low", When: "remaining <= threshold", Template: "low", Level: LevelWarning},
+	{Name: "normal", When: "True", Template: "normal", Level: LevelNormal},
+}
+
+// RuleSet is a compiled list of rules evaluated in order, first match wins
+type RuleSet struct {
+	rules     []Rule
+	templates *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"abs": math.Abs,
+}
+
+// NewRuleSet compiles rules and templates, falling back to the defaults
+func NewRuleSet(rules []Rule, templates map[string]string) (*RuleSet, error) {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	root := template.New("").Funcs(templateFuncs).Option("missingkey=zero")
+	for _, set := range []map[string]string{DefaultTemplates, templates} {
+		for name, text := range set {
+			if _, err := root.New(name).Parse(text); err != nil {
+				return nil, fmt.Errorf("failed to parse template %q: %w", name, err)
+			}
+		}
+	}
+	for _, r := range rules {
+		if root.Lookup(r.Template) == nil {
+			return nil, fmt.Errorf("rule %q uses unknown template %q", r.Name, r.Template)
+		}
+		if r.Public != "" && root.Lookup(r.Public) == nil {
+			return nil, fmt.Errorf("rule %q uses unknown template %q", r.Name, r.Public)
+		}
+		if r.Level != "" && !validLevel(r.Level) {
+			return nil, fmt.Errorf("rule %q has unknown level %q", r.Name, r.Level)
+		}
+		for _, route := range r.Routes {
+			if err := route.Validate(); err != nil {
+				return nil, fmt.Errorf("rule %q has an invalid route: %w", r.Name, err)
+			}
+		}
+	}
+	return &RuleSet{rules: rules, templates: root}, nil
+}
+
+// Evaluate returns the alert produced by the first rule matching vars
+func (rs *RuleSet) Evaluate(vars map[string]interface{}) (Alert, error) {
+	env := starlarkEnv(vars)
+
+	for _, r := range rs.rules {
+		expr := strings.NewReplacer("&&", " and ", "||", " or ").Replace(r.When)
+		thread := &starlark.Thread{Name: "rule " + r.Name}
+		res, err := starlark.Eval(thread, r.Name, expr, env)
+		if err != nil {
+			return Alert{}, fmt.Errorf("failed to evaluate rule %q: %w", r.Name, err)
+		}
+		if !res.Truth() {
+			continue
+		}
+		msg, err := rs.render(r.Template, vars)
+		if err != nil {
+			return Alert{}, err
+		}
+		public := r.Public
+		if public == "" {
+			public = "public"
+		}
+		vars["rule"] = r.Name
+		pub, err := rs.render(public, vars)
+		if err != nil {
+			return Alert{}, err
+		}
+		alert := Alert{Message: msg, Public: pub, Channels: r.Notify, Rule: r.Name, Level: r.Level, Vars: vars}
+		// Keep warnings recognizable on public channels too
+		if alert.IsWarning() && !IsWarning(pub) {
+			alert.Public = "Warning: " + pub
+		}
+		return alert, nil
+	}
+	return Alert{}, fmt.Errorf("no rule matched the reading")
+}
+
+// render executes the named template with vars
+func (rs *RuleSet) render(name string, vars map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := rs.templates.ExecuteTemplate(&buf, name, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+	return buf.String(), nil
+}
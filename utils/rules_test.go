@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// evaluate runs the app's rules on a reading with remaining units left
+func evaluate(t *testing.T, a *App, remaining float64) Alert {
+	t.Helper()
+	if a.Store == nil {
+		a.Store = NopStore{}
+	}
+	alert, err := a.Evaluate(NewReading(100-remaining, 100, "101"))
+	if err != nil {
+		t.Fatalf("Evaluate(%g): %v", remaining, err)
+	}
+	return alert
+}
+
+func TestDefaultRules(t *testing.T) {
+	tests := []struct {
+		remaining float64
+		threshold float64
+		rule      string
+		level     string
+		prefix    string
+	}{
+		{-3.5, 0, "exceeded", LevelCritical, "Warning: Exceeded limit by 3.50!"},
+		{0, 0, "low", LevelWarning, "Warning: Remaining electricity is low: 0.00"},
+		{20, 0, "low", LevelWarning, "Warning: Remaining electricity is low: 20.00"},
+		{20.5, 0, "normal", LevelNormal, "Remaining electricity: 20.50"},
+		{25, 30, "low", LevelWarning, "Warning: Remaining electricity is low"},
+		{35, 30, "normal", LevelNormal, "Remaining electricity: 35.00"},
+	}
+	for _, tt := range tests {
+		a := &App{Settings: Settings{Threshold: tt.threshold}}
+		alert := evaluate(t, a, tt.remaining)
+		if alert.Rule != tt.rule || alert.Severity() != tt.level {
+			t.Errorf("remaining %g, threshold %g: got rule %q level %q, want %q %q",
+				tt.remaining, tt.threshold, alert.Rule, alert.Severity(), tt.rule, tt.level)
+		}
+		if !strings.HasPrefix(alert.Message, tt.prefix) {
+			t.Errorf("remaining %g: message %q, want prefix %q", tt.remaining, alert.Message, tt.prefix)
+		}
+		if alert.IsWarning() != (tt.level != LevelNormal) {
+			t.Errorf("remaining %g: IsWarning() = %v", tt.remaining, alert.IsWarning())
+		}
+		if alert.IsCritical() != (tt.level == LevelCritical) {
+			t.Errorf("remaining %g: IsCritical() = %v", tt.remaining, alert.IsCritical())
+		}
+		if alert.IsWarning() && !strings.HasPrefix(alert.Public, "Warning: ") {
+			t.Errorf("remaining %g: public rendering %q lost the warning prefix", tt.remaining, alert.Public)
+		}
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{"unknown template", []Rule{{Name: "r", When: "True", Template: "missing"}}},
+		{"unknown public template", []Rule{{Name: "r", When: "True", Template: "normal", Public: "missing"}}},
+		{"unknown level", []Rule{{Name: "r", When: "True", Template: "normal", Level: "loud"}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewRuleSet(tt.rules, nil); err == nil {
+			t.Errorf("%s: NewRuleSet accepted %+v", tt.name, tt.rules)
+		}
+	}
+
+	rs, err := NewRuleSet([]Rule{{Name: "r", When: "remaining < 0", Template: "normal"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Evaluate(NewReading(10, 100, "101").Vars()); err == nil {
+		t.Error("Evaluate matched no rule without an error")
+	}
+}
+
+func TestTierRules(t *testing.T) {
+	tiers := []AlertTier{
+		{Below: 20, Level: "warning", Prefix: "Heads up: "},
+		{Below: 50, Level: "info"},
+		{Below: 5, Level: "Critical"},
+	}
+	rules, templates, err := TierRules(tiers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := NewRuleSet(rules, templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{Rules: rs}
+
+	tests := []struct {
+		remaining float64
+		rule      string
+		level     string
+		prefix    string
+	}{
+		{-1, "exceeded", LevelCritical, "Warning: Exceeded limit"},
+		{3, "critical_5", LevelCritical, "Warning: Critical! "},
+		{5, "critical_5", LevelCritical, "Warning: Critical! "},
+		{12, "warning_20", LevelWarning, "Heads up: "},
+		{40, "info_50", LevelInfo, "Info: "},
+		{80, "normal", LevelNormal, "Remaining electricity"},
+	}
+	for _, tt := range tests {
+		alert := evaluate(t, a, tt.remaining)
+		if alert.Rule != tt.rule || alert.Severity() != tt.level {
+			t.Errorf("remaining %g: got rule %q level %q, want %q %q",
+				tt.remaining, alert.Rule, alert.Severity(), tt.rule, tt.level)
+		}
+		if !strings.HasPrefix(alert.Message, tt.prefix) {
+			t.Errorf("remaining %g: message %q, want prefix %q", tt.remaining, alert.Message, tt.prefix)
+		}
+	}
+	// The custom prefix does not hide the warning from routing
+	if alert := evaluate(t, a, 12); !alert.IsWarning() || IsWarning(alert.Message) {
+		t.Errorf("custom warning prefix: IsWarning() = %v for %q", alert.IsWarning(), alert.Message)
+	}
+}
+
+func TestTierRulesKeepExceeded(t *testing.T) {
+	tiers := []AlertTier{{Below: 10, Level: "warning"}}
+	own := []Rule{
+		{Name: "exceeded", When: "remaining < 0", Template: "exceeded", Level: LevelWarning},
+		{Name: "normal", When: "True", Template: "normal"},
+	}
+	rules, _, err := TierRules(tiers, own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "warning_10,exceeded,normal" {
+		t.Errorf("rules %s, want the configured exceeded rule kept once", got)
+	}
+
+	if _, _, err := TierRules([]AlertTier{{Below: 10, Level: "loud"}}, nil); err == nil {
+		t.Error("TierRules accepted an unknown level")
+	}
+}
+
+func TestMessageLevel(t *testing.T) {
+	tests := []struct {
+		msg   string
+		level string
+	}{
+		{"Warning: Exceeded limit by 1.00!", LevelCritical},
+		{"Warning: Critical! Remaining electricity: 2.00", LevelCritical},
+		{"Warning: Remaining electricity is low: 10.00", LevelWarning},
+		{"Info: Remaining electricity: 40.00", LevelInfo},
+		{"Remaining electricity: 80.00", LevelNormal},
+		{"Weekly report", LevelNormal},
+	}
+	for _, tt := range tests {
+		if got := MessageLevel(tt.msg); got != tt.level {
+			t.Errorf("MessageLevel(%q) = %q, want %q", tt.msg, got, tt.level)
+		}
+		// An alert without a level falls back to its message
+		if got := (Alert{Message: tt.msg}).Severity(); got != tt.level {
+			t.Errorf("Severity of %q = %q, want %q", tt.msg, got, tt.level)
+		}
+	}
+	// The level set by a rule wins over the message
+	if (Alert{Message: "Remaining electricity: 1.00", Level: LevelCritical}).IsCritical() != true {
+		t.Error("rule level ignored by IsCritical")
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// RuntimeConfig holds the live configuration behind a lock.
+// Readers get immutable snapshots; writers apply changes to a copy
+// which replaces the current one and bumps the version.
+type RuntimeConfig struct {
+	mu       sync.RWMutex
+	conf     *Config
+	version  uint64
+	watchers []func(conf *Config, version uint64)
+}
+
+// NewRuntimeConfig starts a holder at version 1
+func NewRuntimeConfig(conf *Config) *RuntimeConfig {
+	return &RuntimeConfig{conf: conf, version: 1}
+}
+
+// Get returns the current configuration snapshot and its version.
+// The snapshot must not be modified.
+func (r *RuntimeConfig) Get() (*Config, uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.conf, r.version
+}
+
+// Update applies fn to a deep copy of the configuration and publishes it.
+// Nothing changes when fn returns an error.
+func (r *RuntimeConfig) Update(fn func(conf *Config) error) (uint64, error) {
+	r.mu.Lock()
+	next, err := cloneConfig(r.conf)
+	if err == nil {
+		err = fn(next)
+	}
+	if err != nil {
+		r.mu.Unlock()
+		return 0, err
+	}
+	r.conf = next
+	r.version++
+	version, watchers := r.version, append([]func(*Config, uint64){}, r.watchers...)
+	r.mu.Unlock()
+
+	for _, w := range watchers {
+		w(next, version)
+	}
+	return version, nil
+}
+
+// Replace swaps in a freshly loaded configuration, e.g. on hot reload
+func (r *RuntimeConfig) Replace(conf *Config) uint64 {
+	version, _ := r.Update(func(next *Config) error {
+		*next = *conf
+		return nil
+	})
+	return version
+}
+
+// Reload re-reads the configuration file and replaces the current one
+func (r *RuntimeConfig) Reload(configPath string) (uint64, error) {
+	conf, err := ReadConfig(configPath)
+	if err != nil {
+		return 0, err
+	}
+	return r.Replace(conf), nil
+}
+
+// Watch registers fn to be called after every update
+func (r *RuntimeConfig) Watch(fn func(conf *Config, version uint64)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers = append(r.watchers, fn)
+}
+
+// cloneConfig deep-copies a configuration through its JSON form
+func cloneConfig(conf *Config) (*Config, error) {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var next Config
+	if err := json.Unmarshal(b, &next); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	return &next, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Sanity bounds the values a reading may plausibly have
+type Sanity struct {
+	MaxRatio float64 `example:"10"` // used may exceed total by at most this factor, defaults to 10
+	MaxJump  float64 `example:"20"` // maximum increase of used per hour, defaults to 20
+	Disabled bool
+}
+
+// DataQualityError lists why a reading was rejected
+type DataQualityError struct {
+	Problems []string
+}
+
+func (e *DataQualityError) Error() string {
+	return "implausible reading: " + strings.Join(e.Problems, "; ")
+}
+
+// Check validates a reading against the previous one, if any
+func (s Sanity) Check(r Reading, prev Reading, hasPrev bool) error {
+	if s.Disabled {
+		return nil
+	}
+	ratio, jump := s.MaxRatio, s.MaxJump
+	if ratio <= 0 {
+		ratio = 10
+	}
+	if jump <= 0 {
+		jump = 20
+	}
+
+	var problems []string
+	if r.Total < 0 {
+		problems = append(problems, fmt.Sprintf("negative total %.2f", r.Total))
+	}
+	if r.Used < 0 {
+		problems = append(problems, fmt.Sprintf("negative used %.2f", r.Used))
+	}
+	if r.Total > 0 && r.Used > r.Total*ratio {
+		problems = append(problems, fmt.Sprintf("used %.2f is more than %g times the total %.2f", r.Used, ratio, r.Total))
+	}
+	if hasPrev {
+		// used only grows within a billing period, a drop is a reset
+		hours := r.Timestamp.Sub(prev.Timestamp).Hours()
+		if grown := r.Used - prev.Used; hours > 0 && grown > jump*hours {
+			problems = append(problems, fmt.Sprintf("used jumped by %.2f in %.1f hours", grown, hours))
+		}
+	}
+	if len(problems) > 0 {
+		return &DataQualityError{Problems: problems}
+	}
+	return nil
+}
+
+// checkSanity rejects implausible readings instead of alerting on them.
+// The rejection is reported as a data-quality notice, which is not a
+// warning, so warning-only channels stay quiet.
+func (a *App) checkSanity(r Reading) (bool, error) {
+	prev, ok := a.previous(r)
+	err := a.Sanity.Check(r, prev, ok)
+	if err == nil {
+		return true, nil
+	}
+	log.Print(err)
+	alert := Alert{Message: "Data quality issue, reading ignored: " + err.Error(), Rule: "data-quality", Reading: r}
+	a.publish(Event{Kind: EventDataQuality, Alert: alert, Err: err})
+	return false, a.Notify(alert)
+}
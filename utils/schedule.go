@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule describes when checks and reports run. The daemon follows it;
+// with external cron it only feeds the calendar.
+type Schedule struct {
+	Interval      string `example:"1h"` // time between checks in daemon mode, defaults to 1h
+	Cron          string // standard cron expression, overrides Interval
+	ReportWeekday string `example:"Sunday"` // e.g. "Sunday", empty means no weekly report
+	ReportTime    string `example:"20:00"`  // HH:MM, defaults to 20:00
+}
+
+// NextCheck returns when the next check is due after now
+func (s Schedule) NextCheck(now time.Time) (time.Time, error) {
+	if s.Cron != "" {
+		sched, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid schedule cron %q: %w", s.Cron, err)
+		}
+		return sched.Next(now), nil
+	}
+	interval := time.Hour
+	if s.Interval != "" {
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil || d <= 0 {
+			return time.Time{}, fmt.Errorf("invalid schedule interval %q", s.Interval)
+		}
+		interval = d
+	}
+	return now.Add(interval), nil
+}
+
+// NextReport returns the next weekly report time after now
+func (s Schedule) NextReport(now time.Time) (time.Time, time.Weekday, error) {
+	var day time.Weekday = -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), s.ReportWeekday) {
+			day = d
+		}
+	}
+	if day < 0 {
+		return time.Time{}, day, fmt.Errorf("unknown report weekday %q", s.ReportWeekday)
+	}
+	clock := s.ReportTime
+	if clock == "" {
+		clock = "20:00"
+	}
+	hm, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, day, fmt.Errorf("invalid report time %q", s.ReportTime)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), hm.Hour(), hm.Minute(), 0, 0, now.Location())
+	for t.Weekday() != day || !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, day, nil
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema maps the campus API response to a reading, so that renamed
+// fields only need a config change. Paths are dot-separated keys into
+// the JSON, e.g. data.usedAmp.
+type Schema struct {
+	Name     string `example:"auto"` // built-in schema, "auto" (the default) detects it from the response
+	Used     string // path of the used amount; custom paths replace the named schema
+	Total    string // path of the total quota
+	ReadTime string // path of the meter-read time, optional
+}
+
+// schemas are the response layouts the campus API has been seen with
+var schemas = map[string]Schema{
+	"v1": {Used: "data.usedAmp", Total: "data.allAmp", ReadTime: "data.readTime"},
+}
+
+// AutoSchema detects the fields from the response
+const AutoSchema = "auto"
+
+// Field names tried by auto-detection, compared case-insensitively
+var (
+	usedAliases     = []string{"usedAmp", "used", "usedAmount", "usedPower", "usedEnergy", "useAmp"}
+	totalAliases    = []string{"allAmp", "total", "totalAmount", "allAmount", "totalPower", "totalEnergy"}
+	readTimeAliases = []string{"readTime", "readingTime", "meterTime", "updateTime"}
+)
+
+// Validate checks the schema name and that custom paths come in pairs
+func (s Schema) Validate() error {
+	if s.Name != "" && s.Name != AutoSchema {
+		if _, ok := schemas[s.Name]; !ok {
+			return fmt.Errorf("unknown RequestData.Schema.Name %q, want %s or one of %s", s.Name, AutoSchema, strings.Join(schemaNames(), ", "))
+		}
+	}
+	if (s.Used == "") != (s.Total == "") {
+		return fmt.Errorf("RequestData.Schema needs both Used and Total paths")
+	}
+	return nil
+}
+
+// schemaNames lists the built-in schemas
+func schemaNames() []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve picks the paths for a response: custom paths, a named schema,
+// or what auto-detection finds
+func (s Schema) resolve(doc interface{}) (Schema, error) {
+	if s.Used != "" {
+		return s, nil
+	}
+	if named, ok := schemas[s.Name]; ok {
+		return named, nil
+	}
+	for _, name := range schemaNames() {
+		named := schemas[name]
+		if _, ok := lookup(doc, named.Used); ok {
+			if _, ok := lookup(doc, named.Total); ok {
+				return named, nil
+			}
+		}
+	}
+	found, ok := detectSchema(doc, "")
+	if !ok {
+		return Schema{}, fmt.Errorf("no used and total amounts in the response (keys: %s), set RequestData.Schema", strings.Join(keysOf(doc), ", "))
+	}
+	log.Printf("Campus API response matched no known schema, detected %s and %s; set RequestData.Schema to keep them", found.Used, found.Total)
+	return found, nil
+}
+
+// detectSchema looks for an object holding both a used and a total
+// amount, trying an object before the ones nested in it
+func detectSchema(doc interface{}, prefix string) (Schema, bool) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return Schema{}, false
+	}
+	used, total := findKey(obj, usedAliases), findKey(obj, totalAliases)
+	if used != "" && total != "" {
+		s := Schema{Used: prefix + used, Total: prefix + total}
+		if t := findKey(obj, readTimeAliases); t != "" {
+			s.ReadTime = prefix + t
+		}
+		return s, true
+	}
+	for _, k := range keysOf(obj) {
+		if s, ok := detectSchema(obj[k], prefix+k+"."); ok {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+// findKey returns the key of obj matching one of the aliases with a
+// numeric or string value
+func findKey(obj map[string]interface{}, aliases []string) string {
+	for _, alias := range aliases {
+		for k, v := range obj {
+			if !strings.EqualFold(k, alias) {
+				continue
+			}
+			switch v.(type) {
+			case json.Number, string:
+				return k
+			}
+		}
+	}
+	return ""
+}
+
+// keysOf lists the keys of a JSON object in order
+func keysOf(doc interface{}) []string {
+	obj, _ := doc.(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookup follows a dot-separated path into the document
+func lookup(doc interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return doc, doc != nil
+}
+
+// number reads the amount at path; backends have sent amounts as strings
+func number(doc interface{}, path string) (float64, error) {
+	v, ok := lookup(doc, path)
+	if !ok {
+		return 0, fmt.Errorf("response has no %s", path)
+	}
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return 0, fmt.Errorf("%s is not a number: %v", path, v)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: %q", path, s)
+	}
+	return f, nil
+}
+
+// parse turns a decoded response into a reading of room
+func (s Schema) parse(doc interface{}, room string) (Reading, error) {
+	fields, err := s.resolve(doc)
+	if err != nil {
+		return Reading{}, err
+	}
+	used, err := number(doc, fields.Used)
+	if err != nil {
+		return Reading{}, err
+	}
+	total, err := number(doc, fields.Total)
+	if err != nil {
+		return Reading{}, err
+	}
+	reading := NewReading(used, total, room)
+	if fields.ReadTime == "" {
+		return reading, nil
+	}
+	if v, ok := lookup(doc, fields.ReadTime); ok && fmt.Sprint(v) != "" {
+		if reading.MeterTime, err = parseMeterTime(fmt.Sprint(v)); err != nil {
+			log.Printf("Ignoring meter read time: %v", err)
+		}
+	}
+	return reading, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// listUsers answers with the tenants and their rooms
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.App.State.Users()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []utils.User{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// addUser registers a tenant from {"id": ..., "name": ...}
+func (s *Server) addUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid user", http.StatusBadRequest)
+		return
+	}
+	if err := s.App.State.AddUser(req.ID, req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// removeUser deletes a tenant and its tokens
+func (s *Server) removeUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.App.State.RemoveUser(r.PathValue("user")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// assignRoom gives a tenant access to a served room
+func (s *Server) assignRoom(w http.ResponseWriter, r *http.Request) {
+	room := r.PathValue("room")
+	if s.app(room) == nil {
+		http.Error(w, "unknown room", http.StatusNotFound)
+		return
+	}
+	if err := s.App.State.AssignRoom(r.PathValue("user"), room); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueToken creates a token from {"name": ..., "scopes": [...], "user": ...}
+// and answers with its secret, shown only this once
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		User   string   `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "invalid token request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{utils.ScopeRead}
+	}
+	secret, err := s.App.State.IssueUserToken(req.User, req.Name, req.Scopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"token": secret})
+}
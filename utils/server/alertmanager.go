@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// amPayload is the subset of the Alertmanager webhook format used here
+type amPayload struct {
+	Status string    `json:"status"`
+	Alerts []amAlert `json:"alerts"`
+}
+
+type amAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// alertmanager forwards Alertmanager webhook notifications through the
+// notification channels. Firing alerts are sent as warnings, resolved ones
+// as plain messages; a "channels" label restricts the target channels.
+func (s *Server) alertmanager(w http.ResponseWriter, r *http.Request) {
+	var payload amPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid Alertmanager payload", http.StatusBadRequest)
+		return
+	}
+
+	var failed []string
+	for _, a := range payload.Alerts {
+		alert := utils.Alert{Message: amMessage(a), Rule: "alertmanager"}
+		if channels := a.Labels["channels"]; channels != "" {
+			alert.Channels = strings.Split(channels, ",")
+		}
+		if err := s.App.Notify(alert); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		http.Error(w, strings.Join(failed, "; "), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// amMessage renders one Alertmanager alert as a chat message
+func amMessage(a amAlert) string {
+	name := a.Labels["alertname"]
+	text := a.Annotations["summary"]
+	if text == "" {
+		text = a.Annotations["description"]
+	}
+	if a.Status == "resolved" {
+		return strings.TrimSpace(fmt.Sprintf("Resolved: %s %s", name, text))
+	}
+	return strings.TrimSpace(fmt.Sprintf("Warning: %s %s", name, text))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// maxHistoryDays bounds ?days= of the history endpoint
+const maxHistoryDays = 366
+
+// defaultRoom serves the /api/v1 paths as the room routes of ?room=,
+// or of the main room when none is given
+func (s *Server) defaultRoom(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room := r.URL.Query().Get("room")
+		if room == "" {
+			for _, id := range s.Rooms {
+				if id != "" {
+					room = id
+					break
+				}
+			}
+		}
+		r.SetPathValue("id", room)
+		next(w, r)
+	}
+}
+
+// history returns the stored readings of the last ?days= days, 7 by
+// default, oldest first
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHistoryDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxHistoryDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	history, err := app.Store.History(app.Clock.Now().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []utils.Reading{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
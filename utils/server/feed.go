@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string `xml:"title"`
+	ID      string `xml:"id"`
+	Updated string `xml:"updated"`
+	Content string `xml:"content"`
+}
+
+// feed serves recent readings and threshold alerts as an Atom feed,
+// newest first, for feed readers; times are UTC so they sort as strings
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	room := r.PathValue("id")
+	app := s.app(room)
+	if !s.Feed.Public || app == nil {
+		http.NotFound(w, r)
+		return
+	}
+	days := s.Feed.Days
+	if days <= 0 {
+		days = 7
+	}
+	now := app.Clock.Now()
+	since := now.AddDate(0, 0, -days)
+
+	history, err := app.Store.History(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	alerts, err := s.Audit.Events(since, utils.EventThreshold)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var entries []atomEntry
+	for _, reading := range history {
+		entries = append(entries, atomEntry{
+			Title:   fmt.Sprintf("%.2f units left", reading.Remaining),
+			ID:      fmt.Sprintf("urn:cuhksz-electricity:%s:reading:%d", room, reading.Timestamp.Unix()),
+			Updated: reading.Timestamp.UTC().Format(time.RFC3339),
+			Content: fmt.Sprintf("Used %.2f of %.2f, %.2f remaining.", reading.Used, reading.Total, reading.Remaining),
+		})
+	}
+	for _, e := range alerts {
+		// The feed is public: only this room's alerts, rendered as for
+		// public channels, which skip alerts without a public rendering
+		if e.Room != app.Label || e.Alert.Public == "" {
+			continue
+		}
+		entries = append(entries, atomEntry{
+			Title:   e.Alert.Public,
+			ID:      fmt.Sprintf("urn:cuhksz-electricity:%s:alert:%d", room, e.Time.Unix()),
+			Updated: e.Time.UTC().Format(time.RFC3339),
+			Content: e.Alert.Public,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Updated > entries[j].Updated })
+	if len(entries) > 100 {
+		entries = entries[:100]
+	}
+
+	updated := now.UTC().Format(time.RFC3339)
+	if len(entries) > 0 {
+		updated = entries[0].Updated
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(atomFeed{
+		Title:   "Electricity " + room,
+		ID:      "urn:cuhksz-electricity:" + room,
+		Updated: updated,
+		Entries: entries,
+	})
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+const sessionCookie = "session"
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width">
+<title>Electricity</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 4em">
+<p>Sign in with the Telegram account that receives the alerts.</p>
+<script async src="https://telegram.org/js/telegram-widget.js?22"
+	data-telegram-login="{{.}}" data-size="large" data-auth-url="/login/telegram"></script>
+</body></html>
+`))
+
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width">
+<title>Electricity</title></head>
+<body style="font-family: sans-serif; max-width: 30em; margin: 2em auto">
+{{with .Reading}}<h1>{{printf "%.2f" .Remaining}} left</h1>
+<p>Used {{printf "%.2f" .Used}} of {{printf "%.2f" .Total}}, read {{$.Time}}</p>
+{{else}}<p>No reading recorded yet.</p>{{end}}
+{{with .Forecast}}<p>{{.}}</p>{{end}}
+{{if .Rooms}}<p>{{range .Rooms}}<a href="/?room={{.}}">{{.}}</a> {{end}}</p>{{end}}
+<p><a href="/v1/rooms/{{.Room}}/current">JSON</a> · <a href="/v1/rooms/{{.Room}}/calendar.ics">Calendar</a> · <a href="/logout">Sign out</a></p>
+</body></html>
+`))
+
+// session returns the signed-in Telegram user, if any and still allowed
+func (s *Server) session(r *http.Request) (string, bool) {
+	if !s.Login.Enabled() {
+		return "", false
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	id, ok := utils.VerifySession(s.BotToken, c.Value, time.Now())
+	return id, ok && s.allowed(id)
+}
+
+// allowed reports whether a Telegram user may sign in
+func (s *Server) allowed(id string) bool {
+	_, ok := s.account(id)
+	return ok
+}
+
+// account resolves a Telegram user: nil for the owner's accounts in
+// Login.AllowedIDs, the tenant for registered users, and not ok for
+// anyone else
+func (s *Server) account(id string) (*utils.User, bool) {
+	if id == "" {
+		return nil, false
+	}
+	if slices.Contains(s.Login.AllowedIDs, id) {
+		return nil, true
+	}
+	user, found, err := s.App.State.User(id)
+	if err != nil || !found {
+		return nil, false
+	}
+	return &user, true
+}
+
+// login serves the page with the Telegram login widget
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginPage.Execute(w, s.Login.BotUsername)
+}
+
+// loginCallback verifies the widget's redirect and starts a session
+func (s *Server) loginCallback(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.VerifyTelegramLogin(s.BotToken, r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !s.allowed(id) {
+		log.Printf("Refused dashboard login for Telegram user %s", id)
+		http.Error(w, "this Telegram account is not allowed", http.StatusForbidden)
+		return
+	}
+	expiry := time.Now().Add(s.Login.Session())
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    utils.SignSession(s.BotToken, id, expiry),
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// logout ends the session
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// dashboard shows the latest reading to signed-in users
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.session(r)
+	if !ok || !s.Login.Allows(utils.ScopeRead) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	user, _ := s.account(id)
+	data := struct {
+		Reading  *utils.Reading
+		Time     string
+		Forecast string
+		Room     string
+		Rooms    []string // further rooms the user can switch to
+	}{Room: r.URL.Query().Get("room")}
+	if user == nil {
+		data.Rooms = append(data.Rooms, s.Rooms[0])
+		for name := range s.Apps {
+			data.Rooms = append(data.Rooms, name)
+		}
+		slices.Sort(data.Rooms[1:])
+	} else {
+		data.Rooms = user.Rooms
+	}
+	if data.Room == "" && len(data.Rooms) > 0 {
+		data.Room = data.Rooms[0]
+	}
+	app := s.app(data.Room)
+	if app == nil || !s.canAccess(user, data.Room) {
+		http.Error(w, "no room assigned to this account", http.StatusNotFound)
+		return
+	}
+	if len(data.Rooms) < 2 {
+		data.Rooms = nil
+	}
+	history, err := app.Store.History(app.Clock.Now().AddDate(0, 0, -30))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(history) > 0 {
+		data.Reading = &history[len(history)-1]
+		data.Time = app.Locale.DateTime(data.Reading.Timestamp)
+	}
+	if p, err := app.LastsUntil(app.Clock.Now().AddDate(1, 0, 0)); err == nil {
+		data.Forecast = p.Format(app.Locale)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	dashboardPage.Execute(w, data)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// Server exposes the application core over HTTP
+type Server struct {
+	App   *utils.App
+	Rooms []string              // identifiers of App's room accepted as {id}
+	Apps  map[string]*utils.App // further rooms by name, e.g. the configured Rooms
+	Feed  utils.Feed
+	Audit utils.Audit // source of the alerts in the feed
+	Login utils.Login // Telegram sign-in for the dashboard, AllowedIDs complete
+	API   utils.API   // scopes open on the main room without a token
+	// BotToken verifies Telegram logins and signs sessions
+	BotToken string
+
+	mu sync.Mutex // serializes triggered checks
+}
+
+// Handler returns the routes served by the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/rooms/{id}/current", s.auth(utils.ScopeRead, s.current))
+	mux.HandleFunc("GET /v1/rooms/{id}/check", s.auth(utils.ScopeCheck, s.check))
+	mux.HandleFunc("POST /v1/rooms/{id}/check", s.auth(utils.ScopeCheck, s.check))
+	mux.HandleFunc("GET /v1/rooms/{id}/lasts-until/{date}", s.auth(utils.ScopeRead, s.lastsUntil))
+	mux.HandleFunc("GET /v1/rooms/{id}/diff", s.auth(utils.ScopeRead, s.diff))
+	mux.HandleFunc("GET /v1/rooms/{id}/history", s.auth(utils.ScopeRead, s.history))
+	mux.HandleFunc("GET /v1/rooms/{id}/calendar.ics", s.auth(utils.ScopeRead, s.calendar))
+	mux.HandleFunc("GET /v1/rooms/{id}/feed.atom", s.feed)
+	// Shorter paths for scripts, on the main room or ?room=
+	mux.HandleFunc("GET /api/v1/balance", s.defaultRoom(s.auth(utils.ScopeRead, s.current)))
+	mux.HandleFunc("GET /api/v1/history", s.defaultRoom(s.auth(utils.ScopeRead, s.history)))
+	mux.HandleFunc("POST /api/v1/check", s.defaultRoom(s.auth(utils.ScopeCheck, s.check)))
+	mux.HandleFunc("POST /v1/alertmanager", s.authToken(utils.ScopeNotify, s.alertmanager))
+	mux.HandleFunc("GET /v1/admin/users", s.authToken(utils.ScopeAdmin, s.listUsers))
+	mux.HandleFunc("POST /v1/admin/users", s.authToken(utils.ScopeAdmin, s.addUser))
+	mux.HandleFunc("DELETE /v1/admin/users/{user}", s.authToken(utils.ScopeAdmin, s.removeUser))
+	mux.HandleFunc("PUT /v1/admin/users/{user}/rooms/{room}", s.authToken(utils.ScopeAdmin, s.assignRoom))
+	mux.HandleFunc("POST /v1/admin/tokens", s.authToken(utils.ScopeAdmin, s.issueToken))
+	if s.Login.Enabled() {
+		mux.HandleFunc("GET /{$}", s.dashboard)
+		mux.HandleFunc("GET /login", s.login)
+		mux.HandleFunc("GET /login/telegram", s.loginCallback)
+		mux.HandleFunc("GET /logout", s.logout)
+	}
+	return mux
+}
+
+// auth checks the room, the token scope and, for tenants, the room
+// assignment before calling next
+func (s *Server) auth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room := r.PathValue("id")
+		if s.app(room) == nil {
+			http.Error(w, "unknown room", http.StatusNotFound)
+			return
+		}
+		user, ok := s.identify(r, scope)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Tenants cannot tell other rooms from unknown ones
+		if !s.canAccess(user, room) {
+			http.Error(w, "unknown room", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authToken checks the token scope, or the dashboard session, before
+// calling next; only the owner passes, not tenants
+func (s *Server) authToken(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.identify(r, scope)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if user != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// identify authenticates the request within scope by the dashboard
+// session or a token, and returns the tenant it acts for, nil for the
+// owner
+func (s *Server) identify(r *http.Request, scope string) (*utils.User, bool) {
+	if id, ok := s.session(r); ok && s.Login.Allows(scope) {
+		return s.account(id)
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	// Without a token only the main room is open, not the tenants' rooms
+	if token == "" && s.API.Open(scope) {
+		return &utils.User{Rooms: s.Rooms}, true
+	}
+	t, ok := s.App.State.Identify(token, scope)
+	if !ok || t.User == "" {
+		return nil, ok
+	}
+	user, found, err := s.App.State.User(t.User)
+	if err != nil || !found {
+		return nil, false
+	}
+	return &user, true
+}
+
+// app returns the pipeline of the room {id}, nil when unknown
+func (s *Server) app(id string) *utils.App {
+	if id == "" {
+		return nil
+	}
+	if slices.Contains(s.Rooms, id) {
+		return s.App
+	}
+	return s.Apps[id]
+}
+
+// canAccess reports whether the tenant is assigned the room under any
+// of its identifiers; the owner can access every room
+func (s *Server) canAccess(user *utils.User, id string) bool {
+	if user == nil {
+		return true
+	}
+	if slices.Contains(s.Rooms, id) {
+		return slices.ContainsFunc(s.Rooms, user.CanAccess)
+	}
+	return user.CanAccess(id)
+}
+
+// current returns the latest stored reading
+func (s *Server) current(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	history, err := app.Store.History(app.Clock.Now().AddDate(0, 0, -30))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(history) == 0 {
+		http.Error(w, "no reading recorded yet", http.StatusNotFound)
+		return
+	}
+	writeReading(w, r, app.Locale, history[len(history)-1])
+}
+
+// check runs a full fetch and notify cycle and returns the new reading
+func (s *Server) check(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reading, err := app.Fetch()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	notify := r.URL.Query().Get("notify")
+	_, ok, err := app.Check(reading, notify == "1" || notify == "true")
+	if !ok {
+		if err != nil {
+			log.Print(err)
+		}
+		http.Error(w, "implausible reading ignored", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeReading(w, r, app.Locale, reading)
+}
+
+// lastsUntil projects whether the balance lasts until {date}
+func (s *Server) lastsUntil(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	target, err := utils.ParseTimeAt(r.PathValue("date"), app.Clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := app.LastsUntil(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, p)
+		return
+	}
+	res := map[string]interface{}{
+		"target":    p.Target.Format("2006-01-02"),
+		"known":     p.Known,
+		"lasts":     p.Lasts,
+		"rate":      p.Rate,
+		"remaining": p.Reading.Remaining,
+		"answer":    p.Format(app.Locale),
+	}
+	if !p.Runout.IsZero() {
+		res["runout"] = p.Runout.Format("2006-01-02")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// diff reports the consumption between ?from= and ?to=, which defaults
+// to now
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	q := r.URL.Query()
+	from, err := utils.ParseTimeAt(q.Get("from"), app.Clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := app.Clock.Now()
+	if q.Get("to") != "" {
+		if to, err = utils.ParseTimeAt(q.Get("to"), app.Clock.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	d, err := app.Diff(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if q.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, d.Format(app.Locale))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// calendar serves the iCalendar feed of forecast and report dates
+func (s *Server) calendar(w http.ResponseWriter, r *http.Request) {
+	app := s.app(r.PathValue("id"))
+	ics, err := app.ICS(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	fmt.Fprint(w, ics)
+}
+
+// writeReading answers with JSON, or a short sentence for ?format=text
+// which voice assistants can read out directly
+func writeReading(w http.ResponseWriter, r *http.Request, locale utils.Locale, reading utils.Reading) {
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%.2f units of electricity left as of %s.\n",
+			reading.Remaining, locale.DateTime(reading.Timestamp))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reading)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+// now is when the test server answers
+var now = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestServer serves the main room 101 and the tenant room 202, each
+// with one reading
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	var state utils.StateConfig
+	st, err := state.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newApp := func(label string) *utils.App {
+		store := &utils.FileStore{Path: filepath.Join(dir, "history-"+label+".jsonl")}
+		reading := utils.NewReading(60, 100, "101")
+		reading.Timestamp = now.Add(-time.Hour)
+		if err := store.Save(reading); err != nil {
+			t.Fatal(err)
+		}
+		return &utils.App{Store: store, Clock: &utils.FakeClock{T: now}, State: st, Label: label}
+	}
+	return &Server{
+		App:   newApp(""),
+		Rooms: []string{"101"},
+		Apps:  map[string]*utils.App{"202": newApp("202")},
+		Feed:  utils.Feed{Public: true},
+		Audit: utils.Audit{File: filepath.Join(dir, "audit.jsonl")},
+	}
+}
+
+// get requests path from the server, with the bearer token if any
+func get(s *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestTokenAuth(t *testing.T) {
+	s := newTestServer(t)
+	st := s.App.State
+	read, err := st.IssueToken("phone", []string{utils.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := st.IssueToken("laptop", []string{utils.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddUser("42", "Tenant"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AssignRoom("42", "202"); err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := st.IssueUserToken("42", "tenant", []string{utils.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		open   []string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"no token", nil, "GET", "/v1/rooms/101/current", "", http.StatusUnauthorized},
+		{"wrong token", nil, "GET", "/v1/rooms/101/current", "nope", http.StatusUnauthorized},
+		{"read token", nil, "GET", "/v1/rooms/101/current", read, http.StatusOK},
+		{"token in query", nil, "GET", "/v1/rooms/101/current?token=" + read, "", http.StatusOK},
+		{"read token on another room", nil, "GET", "/v1/rooms/202/current", read, http.StatusOK},
+		{"unknown room", nil, "GET", "/v1/rooms/303/current", read, http.StatusNotFound},
+		{"missing scope", nil, "POST", "/v1/rooms/101/check", read, http.StatusUnauthorized},
+		{"tenant on own room", nil, "GET", "/v1/rooms/202/current", tenant, http.StatusOK},
+		{"tenant on main room", nil, "GET", "/v1/rooms/101/current", tenant, http.StatusNotFound},
+		{"tenant on admin", nil, "GET", "/v1/admin/users", tenant, http.StatusUnauthorized},
+		{"read token on admin", nil, "GET", "/v1/admin/users", read, http.StatusUnauthorized},
+		{"admin token", nil, "GET", "/v1/admin/users", admin, http.StatusOK},
+		{"open read", []string{utils.ScopeRead}, "GET", "/v1/rooms/101/current", "", http.StatusOK},
+		{"open read on short path", []string{utils.ScopeRead}, "GET", "/api/v1/balance", "", http.StatusOK},
+		{"open read on tenant room", []string{utils.ScopeRead}, "GET", "/v1/rooms/202/current", "", http.StatusNotFound},
+		{"open read cannot check", []string{utils.ScopeRead}, "POST", "/v1/rooms/101/check", "", http.StatusUnauthorized},
+		{"open read cannot administer", []string{utils.ScopeRead}, "GET", "/v1/admin/users", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		s.API = utils.API{OpenScopes: tt.open}
+		if w := get(s, tt.method, tt.path, tt.token); w.Code != tt.want {
+			t.Errorf("%s: %s %s answered %d, want %d: %s", tt.name, tt.method, tt.path, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestOpenScopesValidate(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		ok     bool
+	}{
+		{nil, true},
+		{[]string{utils.ScopeRead}, true},
+		{[]string{utils.ScopeCheck}, false},
+		{[]string{utils.ScopeRead, utils.ScopeAdmin}, false},
+	}
+	for _, tt := range tests {
+		if err := (utils.API{OpenScopes: tt.scopes}).Validate(); (err == nil) != tt.ok {
+			t.Errorf("OpenScopes %v: Validate() = %v", tt.scopes, err)
+		}
+	}
+}
+
+func TestFeed(t *testing.T) {
+	s := newTestServer(t)
+	bus := utils.NewBus()
+	s.Audit.Attach(bus)
+	events := []utils.Event{
+		{Room: "", Alert: utils.Alert{Message: "Warning: Remaining electricity is low: 9.00", Public: "Warning: Room 101 is running low"}},
+		{Room: "", Alert: utils.Alert{Message: "Warning: private details only"}},
+		{Room: "202", Alert: utils.Alert{Message: "Warning: low in 202", Public: "Warning: Room 202 is running low"}},
+	}
+	for i, e := range events {
+		e.Kind, e.Time = utils.EventThreshold, now.Add(-time.Duration(i+1)*time.Minute)
+		bus.Publish(e)
+	}
+
+	w := get(s, "GET", "/v1/rooms/101/feed.atom", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("feed answered %d: %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Warning: Room 101 is running low", "40.00 units left"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed lacks %q:\n%s", want, body)
+		}
+	}
+	for _, leak := range []string{"9.00", "private details", "Room 202"} {
+		if strings.Contains(body, leak) {
+			t.Errorf("feed leaks %q:\n%s", leak, body)
+		}
+	}
+
+	w = get(s, "GET", "/v1/rooms/202/feed.atom", "")
+	if body := w.Body.String(); !strings.Contains(body, "Room 202 is running low") || strings.Contains(body, "Room 101") {
+		t.Errorf("feed of room 202 shows other rooms' alerts:\n%s", body)
+	}
+
+	if w := get(s, "GET", "/v1/rooms/303/feed.atom", ""); w.Code != http.StatusNotFound {
+		t.Errorf("feed of an unknown room answered %d", w.Code)
+	}
+	s.Feed.Public = false
+	if w := get(s, "GET", "/v1/rooms/101/feed.atom", ""); w.Code != http.StatusNotFound {
+		t.Errorf("private feed answered %d", w.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ServerChan pushes alerts to WeChat through ServerChan (Server酱)
+type ServerChan struct {
+	SendKey      string
+	APIHost      string // overrides the host derived from the SendKey
+	WarningsOnly bool
+}
+
+// sctpKey matches SendKeys of ServerChan³, which use per-user hosts
+var sctpKey = regexp.MustCompile(`^sctp(\d+)t`)
+
+// Name identifies the ServerChan channel
+func (S *ServerChan) Name() string { return "ServerChan" }
+
+// endpoint returns the push URL for the SendKey
+func (S *ServerChan) endpoint() string {
+	if S.APIHost != "" {
+		return fmt.Sprintf("https://%s/%s.send", S.APIHost, S.SendKey)
+	}
+	if m := sctpKey.FindStringSubmatch(S.SendKey); m != nil {
+		return fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], S.SendKey)
+	}
+	return fmt.Sprintf("https://sctapi.ftqq.com/%s.send", S.SendKey)
+}
+
+// Notify implements Notifier by pushing the message; the first line is
+// the title shown in WeChat, the rest the body
+func (S *ServerChan) Notify(msg string) error {
+	title, desp, _ := strings.Cut(msg, "\n")
+	var res struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := postJSON(S.endpoint(), nil, map[string]string{"title": title, "desp": desp}, &res); err != nil {
+		return fmt.Errorf("failed to send ServerChan push: %w", err)
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("ServerChan push failed: %d %s", res.Code, res.Message)
+	}
+	return nil
+}
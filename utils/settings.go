@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Settings holds the warning threshold and the choices offered by the
+// Telegram /settings panel. Changes made in the panel are kept in State
+// and override the config file: the threshold, Schedule.ReportTime,
+// QuietHours.Default and Locale.Language.
+type Settings struct {
+	Threshold   float64   `example:"20"`                                   // warning level of the built-in rules, the rule variable threshold; defaults to 20
+	Thresholds  []float64 `example:"[10, 20, 30, 50]"`                     // panel choices, defaults to 10, 20, 30 and 50
+	ReportTimes []string  `example:"['08:00', '12:00', '20:00', '22:00']"` // panel choices, defaults to 08:00, 12:00, 20:00 and 22:00
+	QuietHours  []string  `example:"[23:00-07:00, 00:00-08:00]"`           // panel choices besides off, defaults to 23:00-07:00 and 00:00-08:00
+}
+
+// ButtonSettings opens the settings panel; it is also sent for /settings
+const ButtonSettings = "settings"
+
+// settingPrefix starts the callback data of a panel choice, e.g.
+// set:threshold:30
+const settingPrefix = "set:"
+
+// IsSettingsButton reports whether callback data belongs to the settings
+// panel, which only Telegram.UserID may use
+func IsSettingsButton(data string) bool {
+	return data == ButtonSettings || strings.HasPrefix(data, settingPrefix)
+}
+
+// ownerOnly reports whether callback data changes settings, which only
+// Telegram.UserID may do
+func ownerOnly(data string) bool {
+	return IsSettingsButton(data) || data == CommandAway || strings.HasPrefix(data, CommandAway+" ")
+}
+
+// threshold is the warning level of the built-in rules
+func (s Settings) threshold() float64 {
+	if s.Threshold == 0 {
+		return 20
+	}
+	return s.Threshold
+}
+
+// choices of the panel, with the defaults filled in
+func (s Settings) thresholds() []float64 {
+	if len(s.Thresholds) == 0 {
+		return []float64{10, 20, 30, 50}
+	}
+	return s.Thresholds
+}
+
+func (s Settings) reportTimes() []string {
+	if len(s.ReportTimes) == 0 {
+		return []string{"08:00", "12:00", "20:00", "22:00"}
+	}
+	return s.ReportTimes
+}
+
+func (s Settings) quietHours() []string {
+	if len(s.QuietHours) == 0 {
+		return []string{"23:00-07:00", "00:00-08:00"}
+	}
+	return s.QuietHours
+}
+
+// settingsOverrides are the panel's changes as kept in State
+type settingsOverrides struct {
+	Threshold  *float64      `json:",omitempty"`
+	ReportTime *string       `json:",omitempty"`
+	QuietHours *string       `json:",omitempty"` // "" turns quiet hours off
+	Language   *string       `json:",omitempty"`
+	Away       *awayOverride `json:",omitempty"` // set by /away
+}
+
+const settingsKey = "settings"
+
+// applySettings lays the panel's changes over the config file
+func (c *Config) applySettings() error {
+	if c.State.File == "" {
+		return nil
+	}
+	st, err := c.State.Open()
+	if err != nil {
+		return err
+	}
+	var o settingsOverrides
+	if _, err := st.Get(settingsKey, &o); err != nil {
+		return fmt.Errorf("failed to read settings: %w", err)
+	}
+	if o.Threshold != nil {
+		c.Settings.Threshold = *o.Threshold
+	}
+	if o.ReportTime != nil {
+		c.Schedule.ReportTime = *o.ReportTime
+	}
+	if o.QuietHours != nil {
+		c.QuietHours.Default = *o.QuietHours
+	}
+	if o.Language != nil {
+		c.Locale.Language = *o.Language
+	}
+	if o.Away != nil {
+		c.Away.Enabled, c.Away.From, c.Away.Until = o.Away.Enabled, o.Away.From, o.Away.Until
+	}
+	return nil
+}
+
+// HandleSettings shows the settings panel, or validates and saves the
+// choice pressed in it, and returns the reply with the panel's keyboard
+func (a *App) HandleSettings(data string) (reply, markup string, err error) {
+	if data == ButtonSettings {
+		return a.settingsPanel()
+	}
+	if a.State == nil || a.State.path == "" {
+		return "", "", fmt.Errorf("settings need State.File to be kept")
+	}
+	key, value, _ := strings.Cut(strings.TrimPrefix(data, settingPrefix), ":")
+	var o settingsOverrides
+	if _, err := a.State.Get(settingsKey, &o); err != nil {
+		return "", "", err
+	}
+	var done string
+	switch key {
+	case "threshold":
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil || !slices.Contains(a.Settings.thresholds(), t) {
+			return "", "", fmt.Errorf("threshold %q is not one of the choices", value)
+		}
+		o.Threshold, a.Settings.Threshold = &t, t
+		done = fmt.Sprintf("Warning threshold set to %g", t)
+	case "report":
+		if _, err := clockOffset(value); err != nil || !slices.Contains(a.Settings.reportTimes(), value) {
+			return "", "", fmt.Errorf("report time %q is not one of the choices", value)
+		}
+		o.ReportTime, a.Schedule.ReportTime = &value, value
+		done = "Weekly report time set to " + value
+	case "quiet":
+		if value == "off" {
+			value = ""
+		} else if _, _, err := parseWindow(value); err != nil || !slices.Contains(a.Settings.quietHours(), value) {
+			return "", "", fmt.Errorf("quiet hours %q are not one of the choices", value)
+		}
+		o.QuietHours, a.QuietHours.Default = &value, value
+		done = "Quiet hours turned off"
+		if value != "" {
+			done = "Quiet hours set to " + value
+		}
+	case "lang":
+		if err := (Locale{Language: value}).Validate(); err != nil {
+			return "", "", err
+		}
+		o.Language, a.Locale.Language = &value, value
+		done = "Language set to " + value
+	default:
+		return "", "", fmt.Errorf("unknown setting %q", key)
+	}
+	if err := a.State.Put(settingsKey, o); err != nil {
+		return "", "", err
+	}
+	panel, markup, err := a.settingsPanel()
+	return done + ", used from the next check\n\n" + panel, markup, err
+}
+
+// settingsPanel describes the current settings with a keyboard of the
+// choices, one row per setting, the current choice ticked
+func (a *App) settingsPanel() (string, string, error) {
+	type button struct {
+		Text string `json:"text"`
+		Data string `json:"callback_data"`
+	}
+	choice := func(label, data string, current bool) button {
+		if current {
+			label = "✓ " + label
+		}
+		return button{Text: label, Data: settingPrefix + data}
+	}
+
+	var threshold, times, quiet, lang []button
+	for _, t := range a.Settings.thresholds() {
+		threshold = append(threshold, choice(fmt.Sprintf("≤ %g", t), fmt.Sprintf("threshold:%g", t), t == a.Settings.threshold()))
+	}
+	reportTime := a.Schedule.ReportTime
+	if reportTime == "" {
+		reportTime = "20:00"
+	}
+	for _, t := range a.Settings.reportTimes() {
+		times = append(times, choice(t, "report:"+t, t == reportTime))
+	}
+	quiet = append(quiet, choice("Quiet off", "quiet:off", a.QuietHours.Default == ""))
+	for _, w := range a.Settings.quietHours() {
+		quiet = append(quiet, choice(w, "quiet:"+w, w == a.QuietHours.Default))
+	}
+	lang = append(lang,
+		choice("English", "lang:en", !a.Locale.chinese()),
+		choice("中文", "lang:zh", a.Locale.chinese()))
+	markup, err := json.Marshal(map[string][][]button{"inline_keyboard": {threshold, times, quiet, lang}})
+	if err != nil {
+		return "", "", err
+	}
+
+	report := reportTime + " on " + a.Schedule.ReportWeekday
+	if a.Schedule.ReportWeekday == "" {
+		report = reportTime + ", off until Schedule.ReportWeekday is set"
+	}
+	quietHours := a.QuietHours.Default
+	if quietHours == "" {
+		quietHours = "off"
+	}
+	language := "English"
+	if a.Locale.chinese() {
+		language = "中文"
+	}
+	text := fmt.Sprintf("Settings\nWarning threshold: %g\nWeekly report: %s\nQuiet hours: %s\nLanguage: %s",
+		a.Settings.threshold(), report, quietHours, language)
+	return text, string(markup), nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"time"
+)
+
+// pastStore shows a store as it was just before a point in time, so a
+// replayed reading is evaluated without seeing later readings
+type pastStore struct {
+	Store
+	until time.Time
+}
+
+// History returns the stored readings between since and until
+func (p pastStore) History(since time.Time) ([]Reading, error) {
+	history, err := p.Store.History(since)
+	for i, r := range history {
+		if !r.Timestamp.Before(p.until) {
+			return history[:i], err
+		}
+	}
+	return history, err
+}
+
+// Simulate replays the stored readings since a time through the current
+// rules and templates and returns the alert each would have produced,
+// oldest first. Nothing is saved or sent.
+func (a *App) Simulate(since time.Time) ([]Alert, error) {
+	history, err := a.Store.History(since)
+	if err != nil {
+		return nil, err
+	}
+	replay := *a
+	var alerts []Alert
+	for _, r := range history {
+		replay.Store = pastStore{Store: a.Store, until: r.Timestamp}
+		alert, err := replay.Evaluate(r)
+		if err != nil {
+			return alerts, err
+		}
+		alert.ID = ""
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Slack posts alerts to a Slack workspace, either through an incoming
+// webhook or with a bot token via chat.postMessage
+type Slack struct {
+	WebhookURL   string // incoming webhook, takes precedence over Token
+	Token        string // bot token (xoxb-...) for chat.postMessage
+	Channel      string `example:"#electricity"` // channel ID or #name, required with Token
+	APIHost      string // defaults to slack.com
+	WarningsOnly bool
+}
+
+// Configured reports whether Slack delivery is set up
+func (S *Slack) Configured() bool {
+	return S.WebhookURL != "" || S.Token != ""
+}
+
+// Name identifies the Slack channel
+func (S *Slack) Name() string { return "Slack" }
+
+// Notify implements Notifier by posting the message to Slack
+func (S *Slack) Notify(msg string) error {
+	if S.WebhookURL != "" {
+		// Incoming webhooks answer with a plain "ok" body, not JSON
+		return postJSON(S.WebhookURL, nil, map[string]string{"text": msg}, nil)
+	}
+	host := S.APIHost
+	if host == "" {
+		host = "slack.com"
+	}
+	var res struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	err := postJSON(fmt.Sprintf("https://%s/api/chat.postMessage", host),
+		map[string]string{"Authorization": "Bearer " + S.Token},
+		map[string]string{"channel": S.Channel, "text": msg}, &res)
+	if err != nil {
+		return fmt.Errorf("failed to send Slack message: %w", err)
+	}
+	if !res.OK {
+		return fmt.Errorf("Slack chat.postMessage failed: %s", res.Error)
+	}
+	return nil
+}
+
+// postJSON sends body as JSON to url and decodes a JSON response into
+// out, if not nil. Non-2xx statuses are errors.
+func postJSON(url string, headers map[string]string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON payload: %w", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received non-OK HTTP status: %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP sends email through any mail server, e.g. Outlook, QQ Mail or
+// the school's, instead of the Gmail API
+type SMTP struct {
+	Host     string // e.g. smtp.qq.com, enables SMTP when set
+	Port     int    // defaults to 465 with ssl and 587 otherwise
+	Username string
+	Password string // usually an app password or authorization code
+	From     string // sender address, defaults to Username
+	Security string `example:"starttls"` // starttls (default), ssl or none
+}
+
+// Enabled reports whether SMTP replaces the Gmail API
+func (S *SMTP) Enabled() bool { return S.Host != "" }
+
+// Validate checks the security mode, and that credentials are never
+// sent in the clear
+func (S *SMTP) Validate() error {
+	if !S.Enabled() {
+		return nil
+	}
+	switch strings.ToLower(S.Security) {
+	case "", "starttls", "ssl":
+	case "none":
+		if S.Username != "" {
+			return fmt.Errorf("Email.SMTP.Security none would send the password unencrypted, use starttls or ssl with a Username")
+		}
+	default:
+		return fmt.Errorf("unknown Email.SMTP.Security %q, want starttls, ssl or none", S.Security)
+	}
+	return nil
+}
+
+// Send delivers a message built by mimeMessage to the recipients
+func (S *SMTP) Send(recipients []string, message []byte) error {
+	security := strings.ToLower(S.Security)
+	port := S.Port
+	if port == 0 {
+		port = 587
+		if security == "ssl" {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(S.Host, strconv.Itoa(port))
+	from := S.sender()
+
+	var client *smtp.Client
+	var err error
+	switch security {
+	case "ssl":
+		conn, derr := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: S.Host})
+		if derr != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %w", derr)
+		}
+		client, err = smtp.NewClient(conn, S.Host)
+	case "", "starttls", "none":
+		conn, derr := net.DialTimeout("tcp", addr, 30*time.Second)
+		if derr != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %w", derr)
+		}
+		client, err = smtp.NewClient(conn, S.Host)
+	default:
+		return fmt.Errorf("unknown SMTP security %q, want starttls, ssl or none", S.Security)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if security == "" || security == "starttls" {
+		if err := client.StartTLS(&tls.Config{ServerName: S.Host}); err != nil {
+			return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if S.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", S.Username, S.Password, S.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("SMTP server rejected the sender: %w", err)
+	}
+	for _, to := range recipients {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server rejected the recipient %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	log.Println("SMTP push succeeded")
+	return client.Quit()
+}
+
+// sender is the From address
+func (S *SMTP) sender() string {
+	if S.From != "" {
+		return S.From
+	}
+	return S.Username
+}
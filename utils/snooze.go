@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Snooze silences non-critical alerts until a point in time, e.g. during
+// dorm maintenance. Readings are still fetched and stored.
+type Snooze struct {
+	Until    string   // e.g. 2025-02-10T08:00
+	File     string   `example:"config/snooze"` // optional file holding such a time, written by `snooze`
+	Critical []string `example:"[exceeded]"`    // rules that are never silenced, defaults to exceeded
+}
+
+// SnoozedUntil returns the latest configured snooze end, a bare clock
+// time referring to the day of now
+func (S *Snooze) SnoozedUntil(now time.Time) (time.Time, error) {
+	var until time.Time
+	values := []string{S.Until}
+	if S.File != "" {
+		b, err := os.ReadFile(S.File)
+		if err != nil && !os.IsNotExist(err) {
+			return until, fmt.Errorf("failed to read snooze file: %w", err)
+		}
+		values = append(values, strings.TrimSpace(string(b)))
+	}
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		t, err := ParseTimeAt(v, now)
+		if err != nil {
+			return until, err
+		}
+		if t.After(until) {
+			until = t
+		}
+	}
+	return until, nil
+}
+
+// Set writes a snooze end to the snooze file; a zero time clears it
+func (S *Snooze) Set(until time.Time) error {
+	if S.File == "" {
+		return fmt.Errorf("Snooze.File is not configured")
+	}
+	if until.IsZero() {
+		if err := os.Remove(S.File); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(S.File, []byte(until.Format(time.RFC3339)+"\n"), 0600)
+}
+
+// critical reports whether alerts of the rule bypass the snooze
+func (S *Snooze) critical(rule string) bool {
+	critical := S.Critical
+	if len(critical) == 0 {
+		critical = []string{"exceeded"}
+	}
+	for _, c := range critical {
+		if c == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// snoozed reports whether the alert is silenced by the snooze
+func (a *App) snoozed(alert Alert) bool {
+	if a.Snooze.critical(alert.Rule) {
+		return false
+	}
+	until, err := a.Snooze.SnoozedUntil(a.Clock.Now())
+	if err != nil {
+		log.Printf("Ignoring snooze: %v", err)
+		return false
+	}
+	return alert.Reading.Timestamp.Before(until)
+}
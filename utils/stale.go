@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Staleness configures the checks on the meter-read time reported by the
+// API. A meter that stops updating keeps returning the same balance,
+// which otherwise looks like a quiet room.
+type Staleness struct {
+	Flag     int `example:"26"` // hours after which the report notes the meter age, defaults to 26
+	Alert    int `example:"48"` // hours after which a warning is sent once, defaults to 48
+	Disabled bool
+}
+
+const staleKey = "stale_alerted"
+
+// hours converts a configured number of hours, falling back to def
+func hours(h, def int) time.Duration {
+	if h <= 0 {
+		h = def
+	}
+	return time.Duration(h) * time.Hour
+}
+
+// MeterAge is how long before the fetch the meter was last read; it
+// reports false when the API gave no meter time
+func (r Reading) MeterAge() (time.Duration, bool) {
+	if r.MeterTime.IsZero() {
+		return 0, false
+	}
+	return r.Timestamp.Sub(r.MeterTime), true
+}
+
+// applyStaleness notes a stale meter in the alert and sends a separate
+// warning the first time the staleness becomes prolonged
+func (a *App) applyStaleness(alert *Alert) {
+	age, ok := alert.Reading.MeterAge()
+	if a.Staleness.Disabled || !ok || age < hours(a.Staleness.Flag, 26) {
+		return
+	}
+	note := fmt.Sprintf("Meter not updated for %.0fh (last read %s)",
+		age.Hours(), a.Locale.DateTime(alert.Reading.MeterTime))
+	alert.Message += "\n" + note
+	if age < hours(a.Staleness.Alert, 48) || a.State == nil {
+		return
+	}
+
+	// Warn once per meter read, not on every run while it stays stale
+	var alerted time.Time
+	if _, err := a.State.Get(a.roomKey(staleKey), &alerted); err != nil {
+		log.Printf("Failed to read stale meter state: %v", err)
+	}
+	if alerted.Equal(alert.Reading.MeterTime) {
+		return
+	}
+	warning := Alert{Message: "Warning: " + note + ", the balance shown may be out of date",
+		Rule: "stale-meter", Reading: alert.Reading}
+	if err := a.Notify(warning); err != nil {
+		log.Printf("Failed to send stale meter warning: %v", err)
+		return
+	}
+	if err := a.State.Put(a.roomKey(staleKey), alert.Reading.MeterTime); err != nil {
+		log.Printf("Failed to save stale meter state: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// StateConfig points to the file keeping state between runs
+type StateConfig struct {
+	File string `example:"config/state.json"` // JSON file, state only lives in memory when empty
+
+	enc *Encryption
+}
+
+// State is a small key-value store persisted as one JSON object, used
+// for bookkeeping that must survive between one-shot runs
+type State struct {
+	path   string
+	enc    *Encryption
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+// Open loads the state file, starting empty if it does not exist yet
+func (S *StateConfig) Open() (*State, error) {
+	st := &State{path: S.File, enc: S.enc, values: map[string]json.RawMessage{}}
+	if err := st.reload(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// reload reads the state file again, so that changes written by other
+// processes, such as a token issued while serve runs, are seen; the
+// caller holds the lock
+func (s *State) reload() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.values = map[string]json.RawMessage{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if b, err = s.enc.Open(b); err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+	s.values = values
+	return nil
+}
+
+// update re-reads the state file, applies change and writes the file
+// back under a file lock, so that processes sharing the file keep each
+// other's changes
+func (s *State) update(change func() bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		change()
+		return nil
+	}
+	f, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to lock state file: %w", err)
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return fmt.Errorf("failed to lock state file: %w", err)
+	}
+	defer unlockFile(f)
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if !change() {
+		return nil
+	}
+	return s.flush()
+}
+
+// Get decodes the value stored under key into v and reports whether it existed
+func (s *State) Get(key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+	raw, ok := s.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Put stores v under key and writes the state file
+func (s *State) Put(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.update(func() bool {
+		s.values[key] = raw
+		return true
+	})
+}
+
+// Delete removes key and writes the state file
+func (s *State) Delete(key string) error {
+	return s.update(func() bool {
+		if _, ok := s.values[key]; !ok {
+			return false
+		}
+		delete(s.values, key)
+		return true
+	})
+}
+
+// flush writes all values to disk; the caller holds the lock
+func (s *State) flush() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.enc.Enabled() {
+		if err := s.enc.writeSealed(s.path, b); err != nil {
+			return fmt.Errorf("failed to write state file: %w", err)
+		}
+		return nil
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// roomKey qualifies a state key with the room, so rooms sharing the
+// state file keep their own bookkeeping
+func (a *App) roomKey(key string) string {
+	if a.Label == "" {
+		return key
+	}
+	return key + ":" + a.Label
+}
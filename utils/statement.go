@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Statements configures the archived monthly PDF statements, for
+// roommates who keep formal records of the shared electricity
+type Statements struct {
+	Dir       string   `example:"statements"` // where statement-YYYY-MM.pdf is saved, defaults to "statements"
+	Email     bool     // also email each statement as an attachment
+	Roommates []string // split evenly between these when there are no sub-meter readings
+	Font      string   // TrueType font for non-Latin text such as Chinese room names
+}
+
+// Statement is one month's account of the room's electricity
+type Statement struct {
+	Month    time.Time // midnight of the first day
+	Label    string
+	Readings []Reading
+	Days     []DayUsage
+	Used     float64
+	Opening  float64 // remaining before the month
+	Closing  float64 // remaining at its last reading
+	Cost     string  // empty without Pricing
+	Split    []Share
+}
+
+// Share is one roommate's part of the month
+type Share struct {
+	Name    string
+	Used    float64
+	Percent float64
+	Cost    string // empty without Pricing
+}
+
+// Path is where the statement for month is saved
+func (S Statements) Path(month time.Time) string {
+	dir := S.Dir
+	if dir == "" {
+		dir = "statements"
+	}
+	return filepath.Join(dir, "statement-"+month.Format("2006-01")+".pdf")
+}
+
+// Statement accounts for the month containing the given time. The room
+// total is split by the roommates' sub-meter growth over the month when
+// there is any, otherwise evenly between Statements.Roommates.
+func (a *App) Statement(month time.Time, subs []SubReading, roommates []string) (*Statement, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	end := start.AddDate(0, 1, 0)
+	// A week before the month provides the opening balance
+	history, err := a.Store.History(start.AddDate(0, 0, -7))
+	if err != nil {
+		return nil, err
+	}
+	s := &Statement{Month: start, Label: a.Label}
+	var before []Reading
+	for _, r := range history {
+		switch {
+		case r.Timestamp.Before(start):
+			before = append(before, r)
+		case r.Timestamp.Before(end):
+			s.Readings = append(s.Readings, r)
+		}
+	}
+	if len(s.Readings) == 0 {
+		return nil, fmt.Errorf("no readings for %s", start.Format("2006-01"))
+	}
+	s.Opening = s.Readings[0].Remaining
+	if n := len(before); n > 0 {
+		s.Opening = before[n-1].Remaining
+		before = before[n-1:]
+	}
+	s.Closing = s.Readings[len(s.Readings)-1].Remaining
+	s.Days = DailyUsage(append(before, s.Readings...))
+	for _, d := range s.Days {
+		s.Used += d.Used
+	}
+	if a.Pricing.Enabled() {
+		s.Cost = a.Pricing.Format(a.Pricing.Cost(s.Used))
+	}
+
+	var inMonth []SubReading
+	for _, e := range subs {
+		if e.Time.Before(end) {
+			inMonth = append(inMonth, e)
+		}
+	}
+	var metered float64
+	board := Leaderboard(inMonth, start)
+	for _, e := range board {
+		metered += e.Usage
+	}
+	switch {
+	case metered > 0:
+		for _, e := range board {
+			s.Split = append(s.Split, s.share(&a.Pricing, e.Name, e.Usage/metered))
+		}
+	case len(roommates) > 0:
+		for _, name := range roommates {
+			s.Split = append(s.Split, s.share(&a.Pricing, name, 1/float64(len(roommates))))
+		}
+	}
+	return s, nil
+}
+
+// share is a roommate's part of the month for the given fraction
+func (s *Statement) share(p *Pricing, name string, fraction float64) Share {
+	sh := Share{Name: name, Used: s.Used * fraction, Percent: fraction * 100}
+	if p.Enabled() {
+		sh.Cost = p.Format(p.Cost(s.Used) * fraction)
+	}
+	return sh
+}
+
+// Text summarizes the statement, e.g. for the email body
+func (s *Statement) Text() string {
+	text := fmt.Sprintf("Electricity statement for %s", s.Month.Format("January 2006"))
+	if s.Label != "" {
+		text += " (" + s.Label + ")"
+	}
+	text += fmt.Sprintf("\nUsed %.2f, remaining %.2f at the end of the month.", s.Used, s.Closing)
+	if s.Cost != "" {
+		text += "\nCost: " + s.Cost
+	}
+	for _, sh := range s.Split {
+		text += fmt.Sprintf("\n%s: %.2f (%.0f%%)", sh.Name, sh.Used, sh.Percent)
+		if sh.Cost != "" {
+			text += " " + sh.Cost
+		}
+	}
+	return text
+}
+
+// PDF renders the statement on one A4 page with a chart of the balance.
+// Without a TrueType font only Latin text can be shown.
+func (s *Statement) PDF(font string) ([]byte, error) {
+	// gofpdf looks fonts up in its font directory
+	pdf := gofpdf.New("P", "mm", "A4", filepath.Dir(font))
+	family, tr := "Helvetica", pdf.UnicodeTranslatorFromDescriptor("")
+	if font != "" {
+		if _, err := os.Stat(font); err != nil {
+			return nil, fmt.Errorf("statement font: %w", err)
+		}
+		pdf.AddUTF8Font("statement", "", filepath.Base(font))
+		pdf.AddUTF8Font("statement", "B", filepath.Base(font))
+		family, tr = "statement", func(s string) string { return s }
+	}
+	pdf.SetTitle(tr("Electricity statement "+s.Month.Format("2006-01")), font != "")
+	pdf.AddPage()
+
+	pdf.SetFont(family, "B", 18)
+	pdf.CellFormat(0, 10, tr("Electricity statement - "+s.Month.Format("January 2006")), "", 1, "L", false, 0, "")
+	pdf.SetFont(family, "", 11)
+	if s.Label != "" {
+		pdf.CellFormat(0, 7, tr("Room: "+s.Label), "", 1, "L", false, 0, "")
+	}
+	last := s.Month.AddDate(0, 1, -1)
+	pdf.CellFormat(0, 7, fmt.Sprintf("Period: %s to %s, %d readings", s.Month.Format("2006-01-02"), last.Format("2006-01-02"), len(s.Readings)), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	rows := [][2]string{
+		{"Opening balance", fmt.Sprintf("%.2f", s.Opening)},
+		{"Used", fmt.Sprintf("%.2f", s.Used)},
+		{"Closing balance", fmt.Sprintf("%.2f", s.Closing)},
+	}
+	if len(s.Days) > 0 {
+		peak := s.Days[0]
+		for _, d := range s.Days {
+			if d.Used > peak.Used {
+				peak = d
+			}
+		}
+		rows = append(rows, [2]string{"Busiest day", fmt.Sprintf("%s, %.2f", peak.Date.Format("Mon 01-02"), peak.Used)})
+	}
+	if s.Cost != "" {
+		rows = append(rows, [2]string{"Cost", s.Cost})
+	}
+	for _, row := range rows {
+		pdf.SetFont(family, "B", 11)
+		pdf.CellFormat(50, 8, row[0], "1", 0, "L", false, 0, "")
+		pdf.SetFont(family, "", 11)
+		pdf.CellFormat(60, 8, tr(row[1]), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	if len(s.Readings) >= 2 {
+		png, err := Chart(Downsample(s.Readings, Resolution(s.Month.AddDate(0, 1, 0).Sub(s.Month))), 800, 320)
+		if err != nil {
+			return nil, err
+		}
+		opts := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader("chart", opts, bytes.NewReader(png))
+		pdf.SetFont(family, "B", 12)
+		pdf.CellFormat(0, 8, "Remaining balance", "", 1, "L", false, 0, "")
+		pdf.ImageOptions("chart", pdf.GetX(), pdf.GetY(), 180, 0, true, opts, 0, "")
+		pdf.Ln(6)
+	}
+
+	if len(s.Split) > 0 {
+		pdf.SetFont(family, "B", 12)
+		pdf.CellFormat(0, 8, "Roommate split", "", 1, "L", false, 0, "")
+		header := []string{"Name", "Used", "Share"}
+		if s.Cost != "" {
+			header = append(header, "Cost")
+		}
+		pdf.SetFont(family, "B", 11)
+		for _, h := range header {
+			pdf.CellFormat(40, 8, h, "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(family, "", 11)
+		for _, sh := range s.Split {
+			pdf.CellFormat(40, 8, tr(sh.Name), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 8, fmt.Sprintf("%.2f", sh.Used), "1", 0, "R", false, 0, "")
+			pdf.CellFormat(40, 8, fmt.Sprintf("%.1f%%", sh.Percent), "1", 0, "R", false, 0, "")
+			if s.Cost != "" {
+				pdf.CellFormat(40, 8, tr(sh.Cost), "1", 0, "R", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := pdf.Output(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Save writes the PDF into Statements.Dir and returns its path
+func (S Statements) Save(month time.Time, pdf []byte) (string, error) {
+	path := S.Path(month)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, pdf, 0644); err != nil {
+		return "", fmt.Errorf("failed to save statement: %w", err)
+	}
+	return path, nil
+}
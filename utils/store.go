@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store records every reading produced by a run
+type Store interface {
+	Save(r Reading) error
+	History(since time.Time) ([]Reading, error)
+}
+
+// NopStore discards everything it is given
+type NopStore struct{}
+
+func (NopStore) Save(Reading) error                   { return nil }
+func (NopStore) History(time.Time) ([]Reading, error) { return nil, nil }
+
+// StoreConfig selects where readings are kept
+type StoreConfig struct {
+	File   string `example:"config/history.jsonl"` // JSON lines history file, readings are not kept when empty
+	SQLite string // SQLite database, opened by the store package; overrides File
+
+	enc *Encryption
+}
+
+// Open returns the store described by the configuration
+func (S *StoreConfig) Open() (Store, error) {
+	if S.File == "" {
+		return NopStore{}, nil
+	}
+	return &FileStore{Path: S.File, Encryption: S.enc}, nil
+}
+
+// FileStore appends readings to a JSON lines file. With encryption the
+// file is sealed as a whole, so every save rewrites it.
+type FileStore struct {
+	Path       string
+	Encryption *Encryption // optional
+	mu         sync.Mutex
+}
+
+// Save appends a reading to the file
+func (f *FileStore) Save(r Reading) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Encryption.Enabled() {
+		return f.saveSealed(r)
+	}
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(r)
+}
+
+// History returns the readings taken at or after since, oldest first
+func (f *FileStore) History(since time.Time) ([]Reading, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	if b, err = f.Encryption.Open(b); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var readings []Reading
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		var r Reading
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("corrupt history line: %w", err)
+		}
+		if !r.Timestamp.Before(since) {
+			readings = append(readings, r)
+		}
+	}
+	return readings, scanner.Err()
+}
+
+// saveSealed appends a reading by rewriting the whole encrypted file;
+// the caller holds the lock
+func (f *FileStore) saveSealed(r Reading) error {
+	b, err := os.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+	if b, err = f.Encryption.Open(b); err != nil {
+		return fmt.Errorf("failed to read history file: %w", err)
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(append(b, line...), '\n')
+	if err := f.Encryption.writeSealed(f.Path, b); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package store keeps the reading history in a local SQLite database
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/YifanYang6/CUHKSZ-Electricity/utils"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS readings (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	time      INTEGER NOT NULL, -- unix milliseconds
+	used      REAL    NOT NULL,
+	total     REAL    NOT NULL,
+	remaining REAL    NOT NULL,
+	room      TEXT    NOT NULL DEFAULT '',
+	meter     INTEGER NOT NULL DEFAULT 0 -- meter-read unix milliseconds, 0 if unknown
+);
+CREATE INDEX IF NOT EXISTS readings_time ON readings (time);
+`
+
+// SQLite stores readings in a SQLite file; it implements utils.Store
+type SQLite struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+	// Databases created before the meter column existed lack it
+	if _, err := db.Exec(`ALTER TABLE readings ADD COLUMN meter INTEGER NOT NULL DEFAULT 0`); err != nil &&
+		!strings.Contains(err.Error(), "duplicate column") {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate history database: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Close releases the database
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts a reading
+func (s *SQLite) Save(r utils.Reading) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var meter int64
+	if !r.MeterTime.IsZero() {
+		meter = r.MeterTime.UnixMilli()
+	}
+	_, err := s.db.Exec(`INSERT INTO readings (time, used, total, remaining, room, meter) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Timestamp.UnixMilli(), r.Used, r.Total, r.Remaining, r.Room, meter)
+	if err != nil {
+		return fmt.Errorf("failed to save reading: %w", err)
+	}
+	return nil
+}
+
+// History returns the readings taken at or after since, oldest first
+func (s *SQLite) History(since time.Time) ([]utils.Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, err := s.db.Query(`SELECT time, used, total, remaining, room, meter FROM readings WHERE time >= ? ORDER BY time, id`,
+		since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer rows.Close()
+	return scanReadings(rows)
+}
+
+// scanReadings reads the rows of a readings query
+func scanReadings(rows *sql.Rows) ([]utils.Reading, error) {
+	var readings []utils.Reading
+	for rows.Next() {
+		var r utils.Reading
+		var ms, meter int64
+		if err := rows.Scan(&ms, &r.Used, &r.Total, &r.Remaining, &r.Room, &meter); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		r.Timestamp = time.UnixMilli(ms)
+		if meter != 0 {
+			r.MeterTime = time.UnixMilli(meter)
+		}
+		readings = append(readings, r)
+	}
+	return readings, rows.Err()
+}
+
+// Stats summarizes the database
+type Stats struct {
+	Readings int
+	First    time.Time
+	Last     time.Time
+	Bytes    int64
+}
+
+// Stats counts the readings and reports the covered period and file size
+func (s *SQLite) Stats() (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var st Stats
+	var first, last sql.NullInt64
+	err := s.db.QueryRow(`SELECT COUNT(*), MIN(time), MAX(time) FROM readings`).Scan(&st.Readings, &first, &last)
+	if err != nil {
+		return st, fmt.Errorf("failed to read stats: %w", err)
+	}
+	if first.Valid {
+		st.First, st.Last = time.UnixMilli(first.Int64), time.UnixMilli(last.Int64)
+	}
+	var pages, size int64
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return st, err
+	}
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&size); err != nil {
+		return st, err
+	}
+	st.Bytes = pages * size
+	return st, nil
+}
+
+// Vacuum rebuilds the file to reclaim space
+func (s *SQLite) Vacuum() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	return nil
+}
+
+// Snapshot writes a consistent copy of the database to path, which must
+// not exist yet, while other processes may keep writing
+func (s *SQLite) Snapshot(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to snapshot history database: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes the readings taken before a time and returns how many
+func (s *SQLite) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(`DELETE FROM readings WHERE time < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// badRows matches readings that cannot be right: remaining not matching
+// total minus used, or a time that is not a plausible timestamp
+const badRows = `abs(remaining - (total - used)) > 0.01 OR time <= 0`
+
+// Verify runs SQLite's integrity check and counts implausible readings.
+// It returns the problems found, none when the database is healthy.
+func (s *SQLite) Verify() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var problems []string
+	rows, err := s.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check integrity: %w", err)
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	rows.Close()
+
+	var bad, dup int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM readings WHERE ` + badRows).Scan(&bad); err != nil {
+		return nil, err
+	}
+	if bad > 0 {
+		problems = append(problems, fmt.Sprintf("%d implausible readings", bad))
+	}
+	err = s.db.QueryRow(`SELECT COUNT(*) - COUNT(DISTINCT time || '/' || room) FROM readings`).Scan(&dup)
+	if err != nil {
+		return nil, err
+	}
+	if dup > 0 {
+		problems = append(problems, fmt.Sprintf("%d duplicate readings", dup))
+	}
+	return problems, nil
+}
+
+// Repair removes implausible and duplicate readings, rebuilds the
+// indexes and returns how many rows were removed
+func (s *SQLite) Repair() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	for _, stmt := range []string{
+		`DELETE FROM readings WHERE ` + badRows,
+		`DELETE FROM readings WHERE id NOT IN (SELECT MIN(id) FROM readings GROUP BY time, room)`,
+	} {
+		res, err := s.db.Exec(stmt)
+		if err != nil {
+			return removed, fmt.Errorf("failed to repair: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	if _, err := s.db.Exec(`REINDEX`); err != nil {
+		return removed, fmt.Errorf("failed to reindex: %w", err)
+	}
+	return removed, nil
+}
+
+// Summary returns the last reading of every bucket since a time, the
+// buckets aligned like utils.BucketOf; it implements utils.Summarizer
+func (s *SQLite) Summary(since time.Time, bucket time.Duration) ([]utils.Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, offset := since.Zone()
+	// With MAX, SQLite takes the other columns from the row holding the maximum
+	rows, err := s.db.Query(`SELECT time, used, total, remaining, room, meter FROM (
+		SELECT MAX(time) AS time, used, total, remaining, room, meter FROM readings
+		WHERE time >= ? GROUP BY (time + ?) / ?
+	) ORDER BY time`,
+		since.UnixMilli(), int64(offset)*1000, bucket.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize history: %w", err)
+	}
+	defer rows.Close()
+	return scanReadings(rows)
+}
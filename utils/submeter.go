@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SubMeters configures per-roommate sub-meter readings.
+// Every entry is a cumulative counter (e.g. a smart plug's kWh total),
+// so usage over a period is the growth of the counter.
+type SubMeters struct {
+	File  string `example:"config/submeters.json"` // JSON file holding the entries
+	Token string // shared secret required by the webhook, optional
+}
+
+// SubReading is a single sub-meter counter value
+type SubReading struct {
+	Name  string    `json:"name"`
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// LeaderEntry is one line of the usage leaderboard
+type LeaderEntry struct {
+	Name  string
+	Usage float64
+}
+
+var subMeterMu sync.Mutex
+
+// Load reads every recorded sub-meter entry
+func (S *SubMeters) Load() ([]SubReading, error) {
+	b, err := os.ReadFile(S.File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sub-meter file: %w", err)
+	}
+	var entries []SubReading
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse sub-meter file: %w", err)
+	}
+	return entries, nil
+}
+
+// Add records a new sub-meter entry
+func (S *SubMeters) Add(r SubReading) error {
+	if S.File == "" {
+		return fmt.Errorf("SubMeters.File is not configured")
+	}
+	subMeterMu.Lock()
+	defer subMeterMu.Unlock()
+
+	entries, err := S.Load()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, r)
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(S.File, b, 0600)
+}
+
+// Leaderboard ranks sub-meters by counter growth since the given time.
+// The last entry before since serves as baseline when there is one.
+func Leaderboard(entries []SubReading, since time.Time) []LeaderEntry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	base := map[string]float64{}
+	latest := map[string]float64{}
+	for _, e := range entries {
+		if e.Time.Before(since) {
+			base[e.Name] = e.Value
+			continue
+		}
+		if _, ok := base[e.Name]; !ok {
+			base[e.Name] = e.Value
+		}
+		latest[e.Name] = e.Value
+	}
+
+	var board []LeaderEntry
+	for name, value := range latest {
+		board = append(board, LeaderEntry{Name: name, Usage: value - base[name]})
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Usage != board[j].Usage {
+			return board[i].Usage > board[j].Usage
+		}
+		return board[i].Name < board[j].Name
+	})
+	return board
+}
+
+// FormatLeaderboard renders the leaderboard as a chat message
+func FormatLeaderboard(board []LeaderEntry, since time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Electricity leaderboard since %s:", since.Format("2006-01-02"))
+	if len(board) == 0 {
+		sb.WriteString("\nNo sub-meter readings recorded.")
+	}
+	for i, e := range board {
+		fmt.Fprintf(&sb, "\n%d. %s %.2f", i+1, e.Name, e.Usage)
+	}
+	return sb.String()
+}
+
+// Handler accepts smart-meter webhooks posting {"name": ..., "value": ...}
+func (S *SubMeters) Handler(clock Clock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if S.Token != "" && r.URL.Query().Get("token") != S.Token && r.Header.Get("Authorization") != "Bearer "+S.Token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var entry SubReading
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil || entry.Name == "" {
+			http.Error(w, "expected {\"name\": ..., \"value\": ...}", http.StatusBadRequest)
+			return
+		}
+		if entry.Time.IsZero() {
+			entry.Time = clock.Now()
+		}
+		if err := S.Add(entry); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Summary configures the machine-readable end-of-run line
+type Summary struct {
+	File   string // JSON lines file to append to
+	Stdout bool   // print the line to stdout as well
+}
+
+// RunSummary collects the outcome of one run from the event bus
+type RunSummary struct {
+	Time       time.Time        `json:"time"`
+	DurationMS int64            `json:"duration_ms"`
+	AlertID    string           `json:"alert_id,omitempty"`
+	Reading    *Reading         `json:"reading,omitempty"`
+	Rule       string           `json:"rule,omitempty"`
+	Severity   string           `json:"severity"`
+	Attempted  []string         `json:"attempted"`
+	Succeeded  []string         `json:"succeeded"`
+	Failed     []string         `json:"failed"`
+	ChannelMS  map[string]int64 `json:"channel_ms,omitempty"`
+	Error      string           `json:"error,omitempty"`
+
+	mu     sync.Mutex
+	config Summary
+}
+
+// Start subscribes a new summary to the bus, or returns nil when no
+// output is configured
+func (S Summary) Start(bus *Bus) *RunSummary {
+	if S.File == "" && !S.Stdout {
+		return nil
+	}
+	s := &RunSummary{Time: time.Now(), Severity: "info", Attempted: []string{}, Succeeded: []string{},
+		Failed: []string{}, ChannelMS: map[string]int64{}, config: S}
+	bus.Subscribe(s.record)
+	return s
+}
+
+// record folds an event into the summary
+func (s *RunSummary) record(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Kind {
+	case EventReading:
+		r := e.Alert.Reading
+		s.Reading, s.Rule, s.AlertID = &r, e.Alert.Rule, e.Alert.ID
+		if e.Alert.IsWarning() {
+			s.Severity = "warning"
+		}
+	case EventAlertSuppressed:
+		if s.Reading == nil {
+			r := e.Alert.Reading
+			s.Reading, s.Rule, s.Severity = &r, e.Alert.Rule, "suppressed"
+		}
+	case EventFetchFailed:
+		s.Severity = "fetch_failed"
+	case EventDataQuality:
+		s.Severity = "data_quality"
+	case EventDelivered, EventDeliveryFailed:
+		s.Attempted = append(s.Attempted, e.Channel)
+		if e.Kind == EventDelivered {
+			s.Succeeded = append(s.Succeeded, e.Channel)
+		} else {
+			s.Failed = append(s.Failed, e.Channel)
+		}
+		s.ChannelMS[e.Channel] += e.Duration.Milliseconds()
+	}
+}
+
+// Finish writes the summary line with the run's final error
+func (s *RunSummary) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DurationMS = time.Since(s.Time).Milliseconds()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	b, merr := json.Marshal(s)
+	if merr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode run summary: %v\n", merr)
+		return
+	}
+	if s.config.Stdout {
+		fmt.Println(string(b))
+	}
+	if s.config.File != "" {
+		f, err := os.OpenFile(s.config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write run summary: %v\n", err)
+			return
+		}
+		defer f.Close()
+		f.Write(append(b, '\n'))
+	}
+}
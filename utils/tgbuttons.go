@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Callback data of the buttons under a warning
+const (
+	ButtonRefresh = "refresh"
+	ButtonSnooze  = "snooze"
+	ButtonUsage   = "usage"
+)
+
+// CommandLastsUntil asks whether the balance lasts until a date, e.g.
+// /lasts-until 2025-01-31; /lasts_until is accepted too, since Telegram
+// only links commands without hyphens
+const CommandLastsUntil = "/lasts-until"
+
+// alertKeyboard is the reply_markup attached to warnings when
+// Telegram.Buttons is set
+const alertKeyboard = `{"inline_keyboard":[[` +
+	`{"text":"Refresh now","callback_data":"` + ButtonRefresh + `"},` +
+	`{"text":"Snooze 24h","callback_data":"` + ButtonSnooze + `"}],[` +
+	`{"text":"Show 7-day usage","callback_data":"` + ButtonUsage + `"}]]}`
+
+// CallbackQuery is a button press reported by getUpdates
+type CallbackQuery struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Message struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// ChatID is the chat holding the message whose button was pressed
+func (q CallbackQuery) ChatID() string {
+	return strconv.FormatInt(q.Message.Chat.ID, 10)
+}
+
+// callbacks long-polls getUpdates for button presses after offset and
+// returns them with the offset to poll next. Bot commands are returned
+// as presses without a query ID, see botCommand.
+func (T *Telegram) callbacks(ctx context.Context, offset int) ([]CallbackQuery, int, error) {
+	params := url.Values{
+		"offset":          {strconv.Itoa(offset)},
+		"timeout":         {"30"},
+		"allowed_updates": {`["callback_query","message"]`},
+	}
+	posturl := fmt.Sprintf("https://%s/bot%s/getUpdates", T.APIHost, T.BotToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, posturl, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, offset, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := T.client().Do(req)
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to call Telegram getUpdates: %w", err)
+	}
+	defer resp.Body.Close()
+	var updates []struct {
+		UpdateID      int            `json:"update_id"`
+		CallbackQuery *CallbackQuery `json:"callback_query"`
+		Message       *struct {
+			Text string `json:"text"`
+			From struct {
+				ID int64 `json:"id"`
+			} `json:"from"`
+			Chat struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"message"`
+	}
+	if err := decodeTelegram("getUpdates", resp.Body, &updates); err != nil {
+		return nil, offset, err
+	}
+	var queries []CallbackQuery
+	for _, u := range updates {
+		offset = u.UpdateID + 1
+		if u.CallbackQuery != nil {
+			queries = append(queries, *u.CallbackQuery)
+		}
+		if m := u.Message; m != nil {
+			if data, ok := botCommand(m.Text); ok {
+				q := CallbackQuery{Data: data}
+				q.From.ID, q.Message.Chat.ID = m.From.ID, m.Chat.ID
+				queries = append(queries, q)
+			}
+		}
+	}
+	return queries, offset, nil
+}
+
+// botCommand turns a command message into callback data: /settings
+// opens the panel and the other commands are passed on with their
+// arguments. The @BotName groups add to commands is dropped.
+func botCommand(text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", false
+	}
+	switch strings.Split(fields[0], "@")[0] {
+	case "/settings":
+		return ButtonSettings, true
+	case CommandLastsUntil, "/lasts_until":
+		fields[0] = CommandLastsUntil
+	case CommandAway:
+		fields[0] = CommandAway
+	default:
+		return "", false
+	}
+	return strings.Join(fields, " "), true
+}
+
+// IsCommand reports whether callback data is a bot command rather than
+// a button
+func IsCommand(data string) bool {
+	return strings.HasPrefix(data, "/")
+}
+
+// HandleCommand answers a bot command and returns the reply for the chat
+func (a *App) HandleCommand(data string) (string, error) {
+	fields := strings.Fields(data)
+	switch fields[0] {
+	case CommandLastsUntil:
+		if len(fields) != 2 {
+			return "", fmt.Errorf("usage: %s DATE, e.g. %s 2025-01-31", CommandLastsUntil, CommandLastsUntil)
+		}
+		target, err := ParseTimeAt(fields[1], a.Clock.Now())
+		if err != nil {
+			return "", err
+		}
+		p, err := a.LastsUntil(target)
+		if err != nil {
+			return "", err
+		}
+		return p.Format(a.Locale), nil
+	case CommandAway:
+		return a.handleAway(fields[1:])
+	}
+	return "", fmt.Errorf("unknown command %q", fields[0])
+}
+
+// Listen answers button presses in the configured chats with the reply
+// of handle, and the keyboard it returns if any, until ctx is done.
+// Presses from other chats are refused, and settings from anyone but
+// UserID.
+func (T *Telegram) Listen(ctx context.Context, handle func(data string) (reply, markup string, err error)) error {
+	offset := 0
+	for ctx.Err() == nil {
+		queries, next, err := T.callbacks(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Failed to poll Telegram buttons, retrying in a minute: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+		offset = next
+		for _, q := range queries {
+			T.answer(q, handle)
+		}
+	}
+	return ctx.Err()
+}
+
+// answer acknowledges one button press and replies in its chat
+func (T *Telegram) answer(q CallbackQuery, handle func(data string) (string, string, error)) {
+	chatID := q.ChatID()
+	allowed := slices.Contains(T.chatIDs(), chatID)
+	ack := "Working on it…"
+	if !allowed {
+		ack = "This chat is not allowed to use these buttons"
+	} else if ownerOnly(q.Data) && strconv.FormatInt(q.From.ID, 10) != T.UserID {
+		allowed, ack = false, "Only the owner can change settings"
+	}
+	// The spinner on the button stops once the press is answered; a
+	// /settings command has nothing to answer
+	if q.ID != "" {
+		if err := T.call("answerCallbackQuery", url.Values{
+			"callback_query_id": {q.ID},
+			"text":              {ack},
+		}, nil); err != nil {
+			log.Printf("Failed to answer Telegram button: %v", err)
+		}
+	}
+	if !allowed {
+		log.Printf("Ignoring Telegram button %q from user %d in chat %s", q.Data, q.From.ID, chatID)
+		return
+	}
+
+	reply, markup, err := handle(q.Data)
+	if err != nil {
+		reply, markup = "Failed: "+err.Error(), ""
+	}
+	// Replies are plain text, sent with the parse mode of the alerts
+	if err := T.sendTo(chatID, T.escape(reply), false, markup); err != nil {
+		log.Printf("Failed to reply to Telegram button: %v", err)
+	}
+}
+
+// HandleButton carries out the action of a button under a warning and
+// returns the reply for the chat
+func (a *App) HandleButton(data string) (string, error) {
+	switch data {
+	case ButtonRefresh:
+		reading, err := a.Fetch()
+		if err != nil {
+			return "", err
+		}
+		if err := a.Store.Save(reading); err != nil {
+			log.Printf("Failed to save reading: %v", err)
+		}
+		a.shareReading(reading, a.Label)
+		return fmt.Sprintf("Remaining: %.2f at %s", reading.Remaining, a.Locale.DateTime(reading.Timestamp)), nil
+	case ButtonSnooze:
+		until := a.Clock.Now().Add(24 * time.Hour)
+		if err := a.Snooze.Set(until); err != nil {
+			return "", err
+		}
+		return "Non-critical alerts snoozed until " + a.Locale.DateTime(until), nil
+	case ButtonUsage:
+		return a.Report(7)
+	}
+	return "", fmt.Errorf("unknown button %q", data)
+}
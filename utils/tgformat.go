@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Telegram parse modes accepted in Telegram.ParseMode
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+)
+
+// levelEmoji leads each formatted message by its level
+var levelEmoji = map[string]string{
+	LevelCritical: "🚨",
+	LevelWarning:  "⚠️",
+	LevelInfo:     "ℹ️",
+	LevelNormal:   "⚡",
+}
+
+// markdownV2Reserved must be escaped everywhere in MarkdownV2 text
+const markdownV2Reserved = "\\_*[]()~`>#+-=|{}.!"
+
+// escapeMarkdownV2 escapes the characters reserved by MarkdownV2
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Reserved, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parseMode spells Telegram.ParseMode the way the Bot API does
+func (T *Telegram) parseMode() string {
+	for _, mode := range []string{ParseModeMarkdownV2, ParseModeHTML} {
+		if strings.EqualFold(T.ParseMode, mode) {
+			return mode
+		}
+	}
+	return T.ParseMode
+}
+
+// escape makes plain text, such as a button reply, safe to send in
+// Telegram.ParseMode
+func (T *Telegram) escape(text string) string {
+	switch T.parseMode() {
+	case ParseModeMarkdownV2:
+		return escapeMarkdownV2(text)
+	case ParseModeHTML:
+		return html.EscapeString(text)
+	}
+	return text
+}
+
+// NotifyAlert implements AlertNotifier, so that formatted messages can
+// name the room and highlight the balance
+func (T *Telegram) NotifyAlert(alert Alert, msg string) error { return T.notify(msg, &alert) }
+
+// notify sends msg, formatted with alert when there is one. Whether it
+// rings follows the alert's level, or for messages without an alert
+// the level recognized from the message.
+func (T *Telegram) notify(msg string, alert *Alert) error {
+	text, err := T.format(msg, alert)
+	if err != nil {
+		return err
+	}
+	warning, now := IsWarning(msg), time.Now()
+	if alert != nil {
+		warning = alert.IsWarning()
+		// Silent hours follow the app clock, e.g. under -now
+		if !alert.Time.IsZero() {
+			now = alert.Time
+		}
+	}
+	var markup string
+	if T.Buttons && warning && alert != nil {
+		markup = alertKeyboard
+	}
+	return T.send(text, T.silent(warning, now), markup)
+}
+
+// format renders msg for Telegram.ParseMode: an emoji by level, the room
+// in bold when known and the remaining balance in bold where the message
+// shows it. Plain text is returned as is.
+func (T *Telegram) format(msg string, alert *Alert) (string, error) {
+	var escape func(string) string
+	var bold string
+	switch T.parseMode() {
+	case "":
+		return msg, nil
+	case ParseModeMarkdownV2:
+		escape, bold = escapeMarkdownV2, "*%s*"
+	case ParseModeHTML:
+		escape, bold = html.EscapeString, "<b>%s</b>"
+	default:
+		return "", fmt.Errorf("unknown Telegram.ParseMode %q, use %s or %s", T.ParseMode, ParseModeMarkdownV2, ParseModeHTML)
+	}
+
+	level := MessageLevel(msg)
+	if alert != nil {
+		level = alert.Severity()
+	}
+	out := levelEmoji[level] + " "
+	text := escape(msg)
+	if alert != nil && !alert.Reading.Timestamp.IsZero() {
+		if room := alert.Reading.Room; room != "" {
+			out += fmt.Sprintf(bold, escape("Room "+room)) + "\n"
+		}
+		// Only what the message already shows is highlighted, so public
+		// renderings never gain the balance
+		remaining := escape(fmt.Sprintf("%.2f", alert.Reading.Remaining))
+		text = strings.Replace(text, remaining, fmt.Sprintf(bold, remaining), 1)
+	}
+	return out + text, nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Alert levels accepted in AlertTier.Level and Rule.Level
+const (
+	LevelNormal   = "normal"
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// tierPrefixes are the default message prefixes. Warning-only channels
+// recognize warnings by the "Warning" prefix, so critical keeps it.
+var tierPrefixes = map[string]string{
+	LevelInfo:     "Info: ",
+	LevelWarning:  "Warning: ",
+	LevelCritical: "Warning: Critical! ",
+}
+
+// AlertTier is one alert threshold, e.g. 50 info, 20 warning, 5 critical
+type AlertTier struct {
+	Below  float64  // applies when remaining is at or below this
+	Level  string   // info, warning or critical
+	Prefix string   // message prefix, defaults by level
+	Notify []string // channel names, empty means every channel
+}
+
+// TierRules compiles tiers into rules, lowest threshold first, followed
+// by the given rules or the default normal rule when there are none
+func TierRules(tiers []AlertTier, rules []Rule) ([]Rule, map[string]string, error) {
+	sorted := append([]AlertTier(nil), tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Below < sorted[j].Below })
+
+	var out []Rule
+	templates := map[string]string{}
+	for _, t := range sorted {
+		level := strings.ToLower(t.Level)
+		prefix, ok := tierPrefixes[level]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown tier level %q", t.Level)
+		}
+		if t.Prefix != "" {
+			prefix = t.Prefix
+		}
+		name := fmt.Sprintf("%s_%g", level, t.Below)
+		templates["tier_"+name] = prefix + `Remaining electricity: {{printf "%.2f" .remaining}}`
+		out = append(out, Rule{
+			Name:     name,
+			When:     fmt.Sprintf("remaining <= %g", t.Below),
+			Notify:   t.Notify,
+			Template: "tier_" + name,
+			Level:    level,
+		})
+	}
+	// An exceeded limit stays critical whatever the tiers say
+	if !slices.ContainsFunc(rules, func(r Rule) bool { return r.Name == DefaultRules[0].Name }) {
+		out = append([]Rule{DefaultRules[0]}, out...)
+	}
+	if len(rules) == 0 {
+		rules = DefaultRules[len(DefaultRules)-1:]
+	}
+	return append(out, rules...), templates, nil
+}
+
+// validLevel reports whether level is one of the alert levels
+func validLevel(level string) bool {
+	switch level {
+	case LevelNormal, LevelInfo, LevelWarning, LevelCritical:
+		return true
+	}
+	return false
+}
+
+// Severity is the level set by the alert's rule or tier, or for alerts
+// built without one, such as reports, the level recognized from the
+// message
+func (a Alert) Severity() string {
+	if a.Level != "" {
+		return a.Level
+	}
+	return MessageLevel(a.Message)
+}
+
+// IsWarning reports whether the alert is a warning or critical
+func (a Alert) IsWarning() bool {
+	level := a.Severity()
+	return level == LevelWarning || level == LevelCritical
+}
+
+// IsCritical reports whether the alert is an exceeded limit or a
+// critical tier, the ones worth interrupting for
+func (a Alert) IsCritical() bool {
+	return a.Severity() == LevelCritical
+}
+
+// IsCritical reports whether a message without an alert is critical
+func IsCritical(msg string) bool {
+	return MessageLevel(msg) == LevelCritical
+}
+
+// MessageLevel recognizes the level of a message by the default
+// prefixes, for messages that come without an alert; plain warnings
+// count as warning and anything else as normal
+func MessageLevel(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, tierPrefixes[LevelCritical]), strings.HasPrefix(msg, "Warning: Exceeded limit"):
+		return LevelCritical
+	case IsWarning(msg):
+		return LevelWarning
+	case strings.HasPrefix(msg, tierPrefixes[LevelInfo]):
+		return LevelInfo
+	}
+	return LevelNormal
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+)
+
+// Tips configures energy-saving tips appended to high-usage alerts
+type Tips struct {
+	File   string   // JSON object mapping a locale to its list of tips
+	Locale string   `example:"en"`    // e.g. "en" or "zh", defaults to "en"
+	Rules  []string `example:"[low]"` // names of the rules whose alerts get a tip
+}
+
+// DefaultTips are used when no tips file is configured
+var DefaultTips = map[string][]string{
+	"en": {
+		"Tip: setting the AC to 26°C instead of 22°C saves around 15%.",
+		"Tip: close windows and curtains while the AC is running.",
+		"Tip: unplug chargers and monitors on standby when leaving the room.",
+		"Tip: a fan uses a fraction of the power of an AC on mild days.",
+		"Tip: clean the AC filter regularly to keep it efficient.",
+	},
+	"zh": {
+		"小贴士：空调设在 26°C 而不是 22°C 可省电约 15%。",
+		"小贴士：开空调时请关好门窗、拉上窗帘。",
+		"小贴士：离开宿舍时拔掉待机的充电器和显示器。",
+		"小贴士：天气不太热时用风扇代替空调更省电。",
+		"小贴士：定期清洗空调滤网可以保持制冷效率。",
+	},
+}
+
+// Load returns the tips for the configured locale
+func (T *Tips) Load() ([]string, error) {
+	// The file's locales are laid over a copy, keeping DefaultTips intact
+	tips := maps.Clone(DefaultTips)
+	if T.File != "" {
+		b, err := os.ReadFile(T.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tips file: %w", err)
+		}
+		if err := json.Unmarshal(b, &tips); err != nil {
+			return nil, fmt.Errorf("failed to parse tips file: %w", err)
+		}
+	}
+	locale := strings.ToLower(T.Locale)
+	if locale == "" {
+		locale = "en"
+	}
+	if list, ok := tips[locale]; ok {
+		return list, nil
+	}
+	return tips["en"], nil
+}
+
+// Applies reports whether alerts of the named rule should get a tip
+func (T *Tips) Applies(rule string) bool {
+	for _, r := range T.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// pickTip rotates through the tips by day so repeated alerts vary
+func pickTip(tips []string, r Reading) string {
+	if len(tips) == 0 {
+		return ""
+	}
+	return tips[r.Timestamp.YearDay()%len(tips)]
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Token scopes
+const (
+	ScopeRead   = "read"   // read the latest reading
+	ScopeCheck  = "check"  // trigger a fresh check
+	ScopeNotify = "notify" // forward external alerts to the channels
+	ScopeAdmin  = "admin"  // manage users, room assignments and tokens
+)
+
+// APIToken is a scoped credential for a single device. Only the hash of
+// the secret is kept.
+type APIToken struct {
+	Name    string
+	Hash    string
+	Scopes  []string
+	User    string // tenant the token acts for, limited to its rooms; empty for the owner
+	Created time.Time
+}
+
+// API configures the HTTP API of serve
+type API struct {
+	// OpenScopes are granted without a token on the main room, e.g.
+	// read on a home network; only read can be opened, since a check
+	// can notify every channel
+	OpenScopes []string
+}
+
+// Validate checks that only read is opened
+func (A API) Validate() error {
+	for _, scope := range A.OpenScopes {
+		if scope != ScopeRead {
+			return fmt.Errorf("API.OpenScopes cannot open %q, only %s", scope, ScopeRead)
+		}
+	}
+	return nil
+}
+
+// Open reports whether scope needs no token
+func (A API) Open(scope string) bool {
+	for _, s := range A.OpenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const tokensKey = "api_tokens"
+
+// hashToken returns the stored form of a token secret
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tokens lists the issued tokens
+func (s *State) Tokens() ([]APIToken, error) {
+	var tokens []APIToken
+	_, err := s.Get(tokensKey, &tokens)
+	return tokens, err
+}
+
+// IssueToken creates a token for the named device and returns its secret,
+// which is shown only once
+func (s *State) IssueToken(name string, scopes []string) (string, error) {
+	return s.IssueUserToken("", name, scopes)
+}
+
+// IssueUserToken creates a token acting for a tenant, or for the owner
+// when user is empty. Tenants may only read and check their rooms.
+func (s *State) IssueUserToken(user, name string, scopes []string) (string, error) {
+	tokens, err := s.Tokens()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("token %q already exists", name)
+		}
+	}
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeCheck && scope != ScopeNotify && scope != ScopeAdmin {
+			return "", fmt.Errorf("unknown token scope %q", scope)
+		}
+		if user != "" && scope != ScopeRead && scope != ScopeCheck {
+			return "", fmt.Errorf("scope %q is not available to users", scope)
+		}
+	}
+	if user != "" {
+		_, ok, err := s.User(user)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("user %q not found", user)
+		}
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(buf)
+	tokens = append(tokens, APIToken{Name: name, Hash: hashToken(secret), Scopes: scopes, User: user, Created: time.Now()})
+	return secret, s.Put(tokensKey, tokens)
+}
+
+// RevokeToken deletes the named token
+func (s *State) RevokeToken(name string) error {
+	tokens, err := s.Tokens()
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if t.Name == name {
+			return s.Put(tokensKey, append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return fmt.Errorf("token %q not found", name)
+}
+
+// Authorize reports whether secret is a token granting scope
+func (s *State) Authorize(secret, scope string) bool {
+	_, ok := s.Identify(secret, scope)
+	return ok
+}
+
+// Identify returns the token with the secret if it grants scope
+func (s *State) Identify(secret, scope string) (APIToken, bool) {
+	if secret == "" {
+		return APIToken{}, false
+	}
+	tokens, err := s.Tokens()
+	if err != nil {
+		return APIToken{}, false
+	}
+	hash := hashToken(secret)
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		for _, sc := range t.Scopes {
+			if sc == scope {
+				return t, true
+			}
+		}
+	}
+	return APIToken{}, false
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TopUp configures recharge reminders with a per-roommate split
+type TopUp struct {
+	Roommates  []string `example:"[Alice, Bob, Carol]"` // people sharing the bill
+	TargetDate string   `example:"2026-12-20"`          // the balance should last until this day, e.g. 2025-01-10
+	Rounding   float64  `example:"10"`                  // round the suggestion up to a multiple of this, defaults to 10
+	Rules      []string `example:"[low, exceeded]"`     // names of the rules whose alerts get the reminder
+}
+
+// Suggest computes the amount needed to last from now until the target
+// date at ratePerDay on occupied days, rounded up. It returns 0 when the
+// current balance already suffices.
+func (T *TopUp) Suggest(now time.Time, remaining, ratePerDay float64, cal *Calendar) (float64, error) {
+	target, err := ParseTimeAt(T.TargetDate, now)
+	if err != nil {
+		return 0, err
+	}
+	target = target.AddDate(0, 0, 1) // last through the target day
+	needed := ratePerDay*cal.OccupiedDays(now, target) - remaining
+	if needed <= 0 {
+		return 0, nil
+	}
+	step := T.Rounding
+	if step <= 0 {
+		step = 10
+	}
+	return math.Ceil(needed/step) * step, nil
+}
+
+// Reminder phrases the suggestion and who pays what
+func (T *TopUp) Reminder(amount float64) string {
+	if amount <= 0 {
+		return fmt.Sprintf("The balance should last until %s, no top-up needed.", T.TargetDate)
+	}
+	msg := fmt.Sprintf("Suggested top-up: %.2f to last until %s", amount, T.TargetDate)
+	if len(T.Roommates) == 0 {
+		return msg
+	}
+	share := amount / float64(len(T.Roommates))
+	parts := make([]string, len(T.Roommates))
+	for i, name := range T.Roommates {
+		parts[i] = fmt.Sprintf("%s %.2f", name, share)
+	}
+	return msg + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// Applies reports whether alerts of the named rule get the reminder
+func (T *TopUp) Applies(rule string) bool {
+	if T.TargetDate == "" {
+		return false
+	}
+	for _, r := range T.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTopUp appends the top-up reminder to matching alerts
+func (a *App) applyTopUp(alert *Alert) {
+	if !a.TopUp.Applies(alert.Rule) {
+		return
+	}
+	rate, _ := alert.Vars["rate"].(float64)
+	if hasRate, _ := alert.Vars["has_rate"].(bool); !hasRate {
+		return
+	}
+	r := alert.Reading
+	amount, err := a.TopUp.Suggest(r.Timestamp, r.Remaining, rate, &a.Calendar)
+	if err != nil {
+		return
+	}
+	alert.Message += "\n" + a.TopUp.Reminder(amount)
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Twilio sends SMS through Twilio, reaching a phone without data
+// connectivity. Only exceeded-limit and critical alerts are sent.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string // Twilio number in E.164, e.g. +15017122661
+	To         string // recipient number in E.164
+	APIHost    string // defaults to api.twilio.com
+}
+
+// smsLimit is the longest body Twilio accepts
+const smsLimit = 1600
+
+// Name identifies the SMS channel
+func (T *Twilio) Name() string { return "SMS" }
+
+// Notify implements Notifier by sending an SMS
+func (T *Twilio) Notify(msg string) error {
+	host := T.APIHost
+	if host == "" {
+		host = "api.twilio.com"
+	}
+	if r := []rune(msg); len(r) > smsLimit {
+		msg = string(r[:smsLimit])
+	}
+	form := url.Values{"From": {T.From}, "To": {T.To}, "Body": {msg}}
+	endpoint := fmt.Sprintf("https://%s/2010-04-01/Accounts/%s/Messages.json", host, url.PathEscape(T.AccountSID))
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(T.AccountSID, T.AuthToken)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Twilio explains rejected requests in a JSON body
+		var res struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&res)
+		return fmt.Errorf("Twilio rejected the SMS (HTTP %d): %s", resp.StatusCode, res.Message)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// User is a tenant of a shared server: a Telegram account that signs in
+// to the dashboard and sees only the rooms assigned to it
+type User struct {
+	ID      string // Telegram user ID
+	Name    string
+	Rooms   []string // room identifiers as in the API paths
+	Created time.Time
+}
+
+const usersKey = "users"
+
+// CanAccess reports whether the room is assigned to the user
+func (u User) CanAccess(room string) bool {
+	return room != "" && slices.Contains(u.Rooms, room)
+}
+
+// Users lists the tenants
+func (s *State) Users() ([]User, error) {
+	var users []User
+	_, err := s.Get(usersKey, &users)
+	return users, err
+}
+
+// User looks a tenant up by Telegram user ID
+func (s *State) User(id string) (User, bool, error) {
+	users, err := s.Users()
+	if err != nil {
+		return User{}, false, err
+	}
+	for _, u := range users {
+		if u.ID == id {
+			return u, true, nil
+		}
+	}
+	return User{}, false, nil
+}
+
+// AddUser registers a tenant without any rooms
+func (s *State) AddUser(id, name string) error {
+	if id == "" {
+		return errors.New("user ID is empty")
+	}
+	users, err := s.Users()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.ID == id {
+			return fmt.Errorf("user %q already exists", id)
+		}
+	}
+	return s.Put(usersKey, append(users, User{ID: id, Name: name, Created: time.Now()}))
+}
+
+// RemoveUser deletes a tenant together with its tokens
+func (s *State) RemoveUser(id string) error {
+	users, err := s.Users()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(users, func(u User) bool { return u.ID == id })
+	if i < 0 {
+		return fmt.Errorf("user %q not found", id)
+	}
+	tokens, err := s.Tokens()
+	if err != nil {
+		return err
+	}
+	tokens = slices.DeleteFunc(tokens, func(t APIToken) bool { return t.User == id })
+	if err := s.Put(tokensKey, tokens); err != nil {
+		return err
+	}
+	return s.Put(usersKey, slices.Delete(users, i, i+1))
+}
+
+// AssignRoom gives a tenant access to a room; assigning it again is a
+// no-op
+func (s *State) AssignRoom(id, room string) error {
+	users, err := s.Users()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(users, func(u User) bool { return u.ID == id })
+	if i < 0 {
+		return fmt.Errorf("user %q not found", id)
+	}
+	if users[i].CanAccess(room) {
+		return nil
+	}
+	users[i].Rooms = append(users[i].Rooms, room)
+	return s.Put(usersKey, users)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// Webhook posts alerts as JSON to any URL, for integrating downstream
+// systems without code changes
+type Webhook struct {
+	URL          string
+	Headers      map[string]string // sent with every request, e.g. Authorization
+	WarningsOnly bool
+}
+
+// webhookPayload is the JSON body posted to the webhook
+type webhookPayload struct {
+	Message   string    `json:"message"`
+	Severity  string    `json:"severity"` // normal, info, warning or critical
+	Rule      string    `json:"rule,omitempty"`
+	AlertID   string    `json:"alert_id,omitempty"`
+	Room      string    `json:"room,omitempty"`
+	Remaining *float64  `json:"remaining,omitempty"` // kWh, absent when the message has no reading
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Name identifies the webhook channel
+func (W *Webhook) Name() string { return "Webhook" }
+
+// Notify implements Notifier for messages without an alert, such as
+// fetch failures and redeliveries
+func (W *Webhook) Notify(msg string) error {
+	return W.post(webhookPayload{Message: msg, Severity: MessageLevel(msg), Timestamp: time.Now()})
+}
+
+// NotifyAlert implements AlertNotifier by posting the alert's reading
+func (W *Webhook) NotifyAlert(alert Alert, msg string) error {
+	r := alert.Reading
+	p := webhookPayload{
+		Message:   msg,
+		Severity:  alert.Severity(),
+		Rule:      alert.Rule,
+		AlertID:   alert.ID,
+		Room:      r.Room,
+		Timestamp: r.Timestamp,
+	}
+	switch {
+	case !r.Timestamp.IsZero():
+		p.Remaining = &r.Remaining
+	case !alert.Time.IsZero():
+		p.Timestamp = alert.Time
+	default:
+		p.Timestamp = time.Now()
+	}
+	return W.post(p)
+}
+
+// post sends the payload with the configured headers
+func (W *Webhook) post(p webhookPayload) error {
+	if err := postJSON(W.URL, W.Headers, p, nil); err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	return nil
+}
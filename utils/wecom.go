@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WeCom posts alerts through a WeChat Work (企业微信) group robot
+type WeCom struct {
+	WebhookURL   string // https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...
+	MsgType      string `example:"text"` // "text" (default) or "markdown"
+	WarningsOnly bool
+}
+
+// Name identifies the WeCom channel
+func (W *WeCom) Name() string { return "WeCom" }
+
+// Notify implements Notifier for messages without an alert
+func (W *WeCom) Notify(msg string) error { return W.post(msg, IsWarning(msg)) }
+
+// NotifyAlert implements AlertNotifier, coloring by the alert's level
+// rather than its message
+func (W *WeCom) NotifyAlert(alert Alert, msg string) error {
+	return W.post(msg, alert.IsWarning())
+}
+
+// post sends msg to the group robot
+func (W *WeCom) post(msg string, warning bool) error {
+	var body map[string]interface{}
+	switch W.MsgType {
+	case "", "text":
+		body = map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": msg}}
+	case "markdown":
+		body = map[string]interface{}{"msgtype": "markdown", "markdown": map[string]string{"content": weComMarkdown(msg, warning)}}
+	default:
+		return fmt.Errorf("unknown WeCom message type %q", W.MsgType)
+	}
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(W.WebhookURL, nil, body, &res); err != nil {
+		return fmt.Errorf("failed to send WeCom message: %w", err)
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("WeCom robot failed: %d %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// weComMarkdown highlights the first line of a warning in the
+// robot's warning color and keeps the remaining lines as they are
+func weComMarkdown(msg string, warning bool) string {
+	head, rest, _ := strings.Cut(msg, "\n")
+	color := "info"
+	if warning {
+		color = "warning"
+	}
+	out := fmt.Sprintf(`<font color="%s">%s</font>`, color, head)
+	if rest != "" {
+		out += "\n" + rest
+	}
+	return out
+}